@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+)
+
+const (
+	soundBanks     = 8 // Banks A–H
+	allSoundsCount = soundBanks * 128
+
+	allSoundsBank byte = 0x40 // SNDR location 40 00 requests every sound
+)
+
+// SoundArchive holds a full-synth backup: one raw SNDD frame per slot.
+type SoundArchive struct {
+	Created  time.Time       `json:"created"`
+	DeviceID byte            `json:"device_id"`
+	Sounds   []ArchivedSound `json:"sounds"`
+}
+
+type ArchivedSound struct {
+	Bank    string `json:"bank"`
+	Program int    `json:"program"`
+	Name    string `json:"name,omitempty"`
+	SysEx   []byte `json:"sysex"`           // the SNDD frame as received
+	Error   string `json:"error,omitempty"` // set when the frame failed validation
+}
+
+// Invalid returns the sounds whose frame failed validation.
+func (a *SoundArchive) Invalid() []ArchivedSound {
+	var bad []ArchivedSound
+	for _, s := range a.Sounds {
+		if s.Error != "" {
+			bad = append(bad, s)
+		}
+	}
+	return bad
+}
+
+func newArchivedSound(frame []byte) ArchivedSound {
+	s := ArchivedSound{
+		Bank:    byteToBank(frame[5]),
+		Program: int(frame[6]) + 1,
+		SysEx:   frame,
+	}
+
+	sdata, err := checkSNDD(frame)
+	if err != nil {
+		s.Error = err.Error()
+		return s
+	}
+
+	if p, err := ParseSDATA(sdata); err == nil {
+		s.Name = p.Name
+	}
+	return s
+}
+
+// RequestAllSounds asks Blofeld to dump every sound (SNDR 40 00) and collects
+// the SNDD frames for banks A–H. Each frame is checked individually, so a bad
+// checksum marks that slot invalid instead of aborting the backup. progress,
+// if set, is called after every received frame. When the Blofeld stops
+// sending before all slots arrived, the partial archive is returned together
+// with an error.
+func (b *Blofeld) RequestAllSounds(progress func(received, total int)) (*SoundArchive, error) {
+	devID := b.DeviceID()
+	isSNDD := replyTo(devID, 0x10)
+	msgCh, cancel, err := b.inbox.expect(func(msg []byte) bool {
+		return isSNDD(msg) && len(msg) > 6 && msg[5] < soundBanks
+	}, allSoundsCount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for sound dumps: %w", err)
+	}
+	defer cancel()
+
+	log.Printf("Requesting all sounds from device ID 0x%02X", devID)
+	req := []byte{0xF0, 0x3E, 0x13, devID, 0x00, allSoundsBank, 0x00, 0xF7}
+	if err := b.SendSysEx(req); err != nil {
+		return nil, fmt.Errorf("failed to request all sounds: %w", err)
+	}
+
+	archive := &SoundArchive{Created: time.Now(), DeviceID: devID}
+	slots := make(map[int]ArchivedSound, allSoundsCount)
+
+	// One timer for the whole dump, restarted for every frame.
+	timeout := time.NewTimer(5 * time.Second)
+	defer timeout.Stop()
+
+	var timeoutErr error
+	for len(slots) < allSoundsCount && timeoutErr == nil {
+		select {
+		case frame := <-msgCh:
+			slots[int(frame[5])*128+int(frame[6])] = newArchivedSound(frame)
+			if progress != nil {
+				progress(len(slots), allSoundsCount)
+			}
+			timeout.Reset(5 * time.Second)
+		case <-timeout.C:
+			timeoutErr = fmt.Errorf("timed out after %d of %d sounds", len(slots), allSoundsCount)
+		}
+	}
+
+	for _, s := range slots {
+		archive.Sounds = append(archive.Sounds, s)
+	}
+	sort.Slice(archive.Sounds, func(i, j int) bool {
+		if archive.Sounds[i].Bank != archive.Sounds[j].Bank {
+			return archive.Sounds[i].Bank < archive.Sounds[j].Bank
+		}
+		return archive.Sounds[i].Program < archive.Sounds[j].Program
+	})
+
+	return archive, timeoutErr
+}
+
+func ReadSoundArchive(path string) (*SoundArchive, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	archive := &SoundArchive{}
+	if err := json.Unmarshal(data, archive); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal archive %s: %w", path, err)
+	}
+	return archive, nil
+}
+
+func WriteSoundArchive(path string, archive *SoundArchive) error {
+	data, err := json.MarshalIndent(archive, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal archive: %w", err)
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+func backupSounds(blo *Blofeld, args []string) {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	out := fs.String("out", "blofeld-backup-"+time.Now().Format("20060102-150405")+".json", "archive file to write")
+	_ = fs.Parse(args)
+	path := *out
+
+	archive, err := blo.RequestAllSounds(func(received, total int) {
+		if received%64 == 0 || received == total {
+			log.Printf("Received %d/%d sounds\n", received, total)
+		}
+	})
+	if err != nil {
+		if archive == nil || len(archive.Sounds) == 0 {
+			log.Fatalf("backup failed: %v", err)
+		}
+		log.Printf("backup incomplete: %v", err)
+	}
+
+	for _, s := range archive.Invalid() {
+		log.Printf("invalid sound at %s%03d: %s\n", s.Bank, s.Program, s.Error)
+	}
+
+	if err := WriteSoundArchive(path, archive); err != nil {
+		log.Fatalf("failed to write backup: %v", err)
+	}
+	log.Printf("Wrote %d sounds to %s\n", len(archive.Sounds), path)
+}
@@ -5,8 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"log"
-	"math/rand"
 	"os"
+	"sync"
 	"time"
 
 	"gitlab.com/gomidi/midi/v2"
@@ -15,7 +15,17 @@ import (
 
 const PatchSize = 383 // Expected SDATA payload size for a Blofeld patch
 
+// editBufferBank is the location high byte of the edit buffers (spec 2.11).
+const editBufferBank byte = 0x7F
+
+// debugDumps enables dumpBytes, see the -debug flag. Without it a backup
+// or restore would write megabytes of hex.
+var debugDumps bool
+
 func dumpBytes(data []byte, file string) {
+	if !debugDumps {
+		return
+	}
 
 	/*
 		date := time.Now().Format("20060102150405")
@@ -36,86 +46,140 @@ func dumpBytes(data []byte, file string) {
 }
 
 type Oscillator struct {
-	Octave     byte `json:"octave"`
-	Pitch      byte `json:"pitch"` // semitone
-	BendRange  byte `json:"bend_range"`
-	Keytrack   byte `json:"keytrack"`
-	Detune     byte `json:"detune"`
-	Shape      byte `json:"shape"`
-	PW         byte `json:"pw"`
-	PWM        byte `json:"pwm"`
-	PWMSource  byte `json:"pwm_source"`
-	FM         byte `json:"fm"`
-	FMSource   byte `json:"fm_source"`
-	LimitWT    byte `json:"limit_wt"`
-	Brilliance byte `json:"brilliance"`
+	Octave     byte      `json:"octave"`
+	Pitch      byte      `json:"pitch"` // semitone
+	BendRange  byte      `json:"bend_range"`
+	Keytrack   byte      `json:"keytrack"`
+	Detune     byte      `json:"detune"`
+	Shape      OscShape  `json:"shape"`
+	PW         byte      `json:"pw"`
+	PWM        byte      `json:"pwm"`
+	PWMSource  ModSource `json:"pwm_source"`
+	FM         byte      `json:"fm"`
+	FMSource   FMSource  `json:"fm_source"`
+	LimitWT    byte      `json:"limit_wt"`
+	Brilliance byte      `json:"brilliance"`
 }
 
 type Filter struct {
-	Type       byte `json:"type"`
-	Cutoff     byte `json:"cutoff"`
-	Res        byte `json:"res"`
-	Drive      byte `json:"drive"`
-	DriveCurve byte `json:"drive_curve"`
-	EnvAmt     byte `json:"env_amt"`
-	EnvVel     byte `json:"env_vel"`
-	Keytrack   byte `json:"keytrack"`
-	ModSource  byte `json:"mod_source"`
-	ModAmount  byte `json:"mod_amount"`
-	FMSource   byte `json:"fm_source"`
-	FMAmount   byte `json:"fm_amount"`
-	Pan        byte `json:"pan"`
-	PanSource  byte `json:"pan_source"`
-	PanAmount  byte `json:"pan_amount"`
+	Type       FilterType `json:"type"`
+	Cutoff     byte       `json:"cutoff"`
+	Res        byte       `json:"res"`
+	Drive      byte       `json:"drive"`
+	DriveCurve DriveCurve `json:"drive_curve"`
+	EnvAmt     byte       `json:"env_amt"`
+	EnvVel     byte       `json:"env_vel"`
+	Keytrack   byte       `json:"keytrack"`
+	ModSource  ModSource  `json:"mod_source"`
+	ModAmount  byte       `json:"mod_amount"`
+	FMSource   FMSource   `json:"fm_source"`
+	FMAmount   byte       `json:"fm_amount"`
+	Pan        byte       `json:"pan"`
+	PanSource  ModSource  `json:"pan_source"`
+	PanAmount  byte       `json:"pan_amount"`
 }
 
 type Envelope struct {
-	Mode        byte `json:"mode"`
-	Attack      byte `json:"attack"`
-	AttackLevel byte `json:"attack_level"`
-	Decay       byte `json:"decay"`
-	Sustain     byte `json:"sustain"`
-	Decay2      byte `json:"decay2"`
-	Sustain2    byte `json:"sustain2"`
-	Release     byte `json:"release"`
+	Mode        EnvMode    `json:"mode"`
+	Trigger     EnvTrigger `json:"trigger"`
+	Attack      byte       `json:"attack"`
+	AttackLevel byte       `json:"attack_level"`
+	Decay       byte       `json:"decay"`
+	Sustain     byte       `json:"sustain"`
+	Decay2      byte       `json:"decay2"`
+	Sustain2    byte       `json:"sustain2"`
+	Release     byte       `json:"release"`
+}
+
+// Unison is the packed allocation byte (spec 4.10, 0uuu000a).
+type Unison struct {
+	Voices byte `json:"voices"` // 0 = off, 1 = dual, 2..5 = 3..6 voices
+	Mono   bool `json:"mono"`
+}
+
+const unisonMask = 0x71
+
+func unpackUnison(b byte) Unison {
+	return Unison{Voices: b >> 4 & 0x07, Mono: b&0x01 != 0}
+}
+
+func (u Unison) pack() byte {
+	b := (u.Voices & 0x07) << 4
+	if u.Mono {
+		b |= 0x01
+	}
+	return b
+}
+
+// ArpStep is one packed arpeggiator pattern step (0sssgaaa).
+type ArpStep struct {
+	Kind   ArpStepKind `json:"kind"`
+	Glide  bool        `json:"glide"`
+	Accent byte        `json:"accent"` // 0 = silent, 1..7 = -96..+96
+}
+
+func unpackArpStep(b byte) ArpStep {
+	return ArpStep{Kind: ArpStepKind(b >> 4 & 0x07), Glide: b&0x08 != 0, Accent: b & 0x07}
+}
+
+func (s ArpStep) pack() byte {
+	b := byte(s.Kind)&0x07<<4 | s.Accent&0x07
+	if s.Glide {
+		b |= 0x08
+	}
+	return b
+}
+
+// ArpTiming is one packed arpeggiator pattern timing/length byte (0lll0ttt).
+type ArpTiming struct {
+	Length byte `json:"length"` // 0 = legato, 1..7 = -3..+3
+	Timing byte `json:"timing"` // 0 = random, 1..7 = -3..+3
+}
+
+const arpTimingMask = 0x77
+
+func unpackArpTiming(b byte) ArpTiming {
+	return ArpTiming{Length: b >> 4 & 0x07, Timing: b & 0x07}
+}
+
+func (t ArpTiming) pack() byte {
+	return t.Length&0x07<<4 | t.Timing&0x07
 }
 
 type LFO struct {
-	Shape      byte `json:"shape"`
-	Speed      byte `json:"speed"`
-	Sync       byte `json:"sync"`
-	Clocked    byte `json:"clocked"`
-	StartPhase byte `json:"start_phase"`
-	Delay      byte `json:"delay"`
-	Fade       byte `json:"fade"`
-	Keytrack   byte `json:"keytrack"`
+	Shape      LFOShape `json:"shape"`
+	Speed      byte     `json:"speed"`
+	Sync       byte     `json:"sync"`
+	Clocked    byte     `json:"clocked"`
+	StartPhase byte     `json:"start_phase"`
+	Delay      byte     `json:"delay"`
+	Fade       byte     `json:"fade"`
+	Keytrack   byte     `json:"keytrack"`
 }
 
 type Effect struct {
-	Type   byte     `json:"type"`
-	Mix    byte     `json:"mix"`
-	Param1 byte     `json:"param1"`
-	Param2 byte     `json:"param2"`
-	Params [14]byte `json:"params"`
+	Type   EffectType `json:"type"`
+	Mix    byte       `json:"mix"`
+	Params [14]byte   `json:"params"`
 }
 
 type ModulationMatrix struct {
-	Source byte `json:"source"`
-	Amount byte `json:"amount"`
-	Dest   byte `json:"dest"`
+	Source ModSource `json:"source"`
+	Amount byte      `json:"amount"`
+	Dest   ModDest   `json:"dest"`
 }
 
 type Modifier struct {
-	SourceA  byte `json:"source_a"`
-	SourceB  byte `json:"source_b"`
-	Operator byte `json:"operator"`
-	Constant byte `json:"constant"`
+	SourceA  ModSource   `json:"source_a"`
+	SourceB  ModSource   `json:"source_b"`
+	Operator ModOperator `json:"operator"`
+	Constant byte        `json:"constant"`
 }
 
 type Patch struct {
 	Oscillators    [3]Oscillator `json:"oscillators"`
 	Osc2Sync       byte          `json:"osc2_sync"`
-	OscPitchSource byte          `json:"osc_pitch_source"`
+	OscPitchSource ModSource     `json:"osc_pitch_source"`
 	OscPitchAmount byte          `json:"osc_pitch_amount"`
 
 	Filters [2]Filter `json:"filters"`
@@ -133,13 +197,14 @@ type Patch struct {
 	MixRing         byte `json:"mix_ring"`
 	MixRingBalance  byte `json:"mix_ring_balance"`
 
-	FilterRouting byte `json:"filter_routing"`
-	GlideMode     byte `json:"glide_mode"`
-	GlideRate     byte `json:"glide_rate"`
-	Unison        byte `json:"unison"`
-	UnisonDetune  byte `json:"unison_detune"`
+	FilterRouting byte   `json:"filter_routing"`
+	Glide         byte   `json:"glide"`
+	GlideMode     byte   `json:"glide_mode"`
+	GlideRate     byte   `json:"glide_rate"`
+	Unison        Unison `json:"unison"`
+	UnisonDetune  byte   `json:"unison_detune"`
 
-	Envelopes [3]Envelope `json:"envelopes"`
+	Envelopes [4]Envelope `json:"envelopes"`
 
 	LFOs [3]LFO `json:"lfos"`
 
@@ -148,31 +213,31 @@ type Patch struct {
 	Modifiers [4]Modifier          `json:"modifiers"`
 
 	// Arpeggiator section
-	ArpMode          byte     `json:"arp_mode"`
-	ArpPattern       byte     `json:"arp_pattern"`
-	ArpClock         byte     `json:"arp_clock"`
-	ArpLength        byte     `json:"arp_length"`
-	ArpRange         byte     `json:"arp_range"`
-	ArpDirection     byte     `json:"arp_direction"`
-	ArpSort          byte     `json:"arp_sort"`
-	ArpVelocityMode  byte     `json:"arp_velocity_mode"`
-	ArpTimingFactor  byte     `json:"arp_timing_factor"`
-	ArpPatternReset  byte     `json:"arp_pattern_reset"`
-	ArpPatternLength byte     `json:"arp_pattern_length"`
-	ArpTempo         byte     `json:"arp_tempo"`
-	ArpPatternSteps  [16]byte `json:"arp_pattern_steps"`
-	ArpPatternTiming [16]byte `json:"arp_pattern_timing"`
+	ArpMode          byte          `json:"arp_mode"`
+	ArpPattern       byte          `json:"arp_pattern"`
+	ArpClock         byte          `json:"arp_clock"`
+	ArpLength        byte          `json:"arp_length"`
+	ArpRange         byte          `json:"arp_range"`
+	ArpDirection     byte          `json:"arp_direction"`
+	ArpSort          byte          `json:"arp_sort"`
+	ArpVelocityMode  byte          `json:"arp_velocity_mode"`
+	ArpTimingFactor  byte          `json:"arp_timing_factor"`
+	ArpPatternReset  byte          `json:"arp_pattern_reset"`
+	ArpPatternLength byte          `json:"arp_pattern_length"`
+	ArpTempo         byte          `json:"arp_tempo"`
+	ArpPatternSteps  [16]ArpStep   `json:"arp_pattern_steps"`
+	ArpPatternTiming [16]ArpTiming `json:"arp_pattern_timing"`
 
 	// FX
 	Effects [2]Effect `json:"effects"`
 
 	// Amp
-	AmpVolume    byte `json:"amp_volume"`
-	AmpVelocity  byte `json:"amp_velocity"`
-	AmpModSource byte `json:"amp_mod_source"`
-	AmpModAmount byte `json:"amp_mod_amount"`
-	AmpPan       byte `json:"amp_pan"`
-	AmpDrive     byte `json:"amp_drive"`
+	AmpVolume    byte      `json:"amp_volume"`
+	AmpVelocity  byte      `json:"amp_velocity"`
+	AmpModSource ModSource `json:"amp_mod_source"`
+	AmpModAmount byte      `json:"amp_mod_amount"`
+	AmpPan       byte      `json:"amp_pan"`
+	AmpDrive     byte      `json:"amp_drive"`
 
 	// Master tuning + globals
 	MasterTune byte `json:"master_tune"`
@@ -181,11 +246,14 @@ type Patch struct {
 	Name string `json:"name"`
 
 	// Category + Subcategory
-	Category    byte `json:"category"`
-	SubCategory byte `json:"subcategory"`
-}
+	Category    Category `json:"category"`
+	SubCategory byte     `json:"subcategory"`
 
-var random = rand.New(rand.NewSource(time.Now().UnixNano()))
+	// Raw is the SDATA the patch was parsed from. ToSDATA starts from it, so
+	// reserved bytes, unused bits and undocumented firmware data survive an
+	// edit; only the parameters above are overwritten.
+	Raw []byte `json:"raw,omitempty"`
+}
 
 // Index mappings into the 383-byte SDATA payload (see Blofeld spec 3.1).
 var oscFieldMapping = []struct {
@@ -242,6 +310,7 @@ var envelopeFieldMapping = []struct {
 	{mode: 196, attack: 199, attackLevel: 200, decay: 201, sustain: 202, decay2: 203, sustain2: 204, release: 205}, // Filter envelope
 	{mode: 208, attack: 211, attackLevel: 212, decay: 213, sustain: 214, decay2: 215, sustain2: 216, release: 217}, // Amp envelope
 	{mode: 220, attack: 223, attackLevel: 224, decay: 225, sustain: 226, decay2: 227, sustain2: 228, release: 229}, // Envelope 3
+	{mode: 232, attack: 235, attackLevel: 236, decay: 237, sustain: 238, decay2: 239, sustain2: 240, release: 241}, // Envelope 4
 }
 
 var lfoFieldMapping = []struct {
@@ -262,18 +331,17 @@ var lfoFieldMapping = []struct {
 var effectFieldMapping = []struct {
 	typ         int
 	mix         int
-	param1      int
-	param2      int
 	paramsStart int
 }{
-	{typ: 128, mix: 129, param1: 130, param2: 131, paramsStart: 130},
-	{typ: 144, mix: 145, param1: 146, param2: 147, paramsStart: 146},
+	{typ: 128, mix: 129, paramsStart: 130},
+	{typ: 144, mix: 145, paramsStart: 146},
 }
 
 const (
 	oscSyncIdx        = 49
 	oscPitchSourceIdx = 50
 	oscPitchAmountIdx = 51
+	glideIdx          = 53
 	glideModeIdx      = 56
 	glideRateIdx      = 57
 	unisonIdx         = 58
@@ -298,7 +366,12 @@ const (
 	ampModSourceIdx = 123
 	ampModAmountIdx = 124
 
-	masterTuneIdx = 52 // Reserved slot; kept to preserve round-tripping of the struct field
+	masterTuneIdx = 52 // Reserved slot; kept for JSON compatibility, Raw preserves the rest
+
+	nameIdx        = 363
+	nameLength     = 16
+	categoryIdx    = 379
+	subCategoryIdx = 380 // Reserved slot; kept for JSON compatibility
 
 	modMatrixStartIdx = 261
 	modMatrixStride   = 3
@@ -321,33 +394,12 @@ const (
 	arpPatternTimingStartIdx = 343
 )
 
-// RandomizeOscillators mutates only oscillator-related parameters using a PRNG.
-func (p *Patch) RandomizeOscillators() {
-
-	randByte := func() byte {
-		return byte(random.Intn(128)) // constrain to 0–127 MIDI range
-	}
-
-	// Limit shape to the first 5 oscillator types (0–4), avoiding custom samples/wavetables.
-	randShape := func() byte {
-		return byte(random.Intn(5))
-	}
-
-	for i := range p.Oscillators {
-		p.Oscillators[i].Shape = randShape()
-		p.Oscillators[i].Octave = randByte()
-		p.Oscillators[i].Pitch = randByte()
-		p.Oscillators[i].BendRange = randByte()
-		p.Oscillators[i].Keytrack = randByte()
-		p.Oscillators[i].Detune = randByte()
-		p.Oscillators[i].PW = randByte()
-		p.Oscillators[i].PWM = randByte()
-		p.Oscillators[i].PWMSource = randByte()
-		p.Oscillators[i].FM = randByte()
-		p.Oscillators[i].FMSource = randByte()
-		p.Oscillators[i].LimitWT = randByte()
-		p.Oscillators[i].Brilliance = randByte()
-	}
+// RandomizeOscillators draws new values for the oscillator section, within
+// the valid ranges. The same seed gives the same oscillators; see Randomize
+// for more control.
+func (p *Patch) RandomizeOscillators(seed int64) {
+	// The section name is known and the amount in range, so this cannot fail.
+	_ = p.Randomize(RandomizeOptions{Sections: []string{"oscillators"}, Amount: 1, Seed: seed})
 }
 
 func ParseSDATA(data []byte) (*Patch, error) {
@@ -355,7 +407,7 @@ func ParseSDATA(data []byte) (*Patch, error) {
 		return nil, errors.New("invalid SDATA length")
 	}
 
-	p := &Patch{}
+	p := &Patch{Raw: append([]byte(nil), data...)}
 
 	// Map oscillator fields per Blofeld spec indexes (3.1 SDATA table).
 	for i, m := range oscFieldMapping {
@@ -373,12 +425,12 @@ func ParseSDATA(data []byte) (*Patch, error) {
 			p.Oscillators[i].Keytrack = data[m.keytrack]
 		}
 		p.Oscillators[i].Detune = data[m.detune]
-		p.Oscillators[i].FMSource = data[m.fmSource]
+		p.Oscillators[i].FMSource = FMSource(data[m.fmSource])
 		p.Oscillators[i].FM = data[m.fm]
-		p.Oscillators[i].Shape = data[m.shape]
+		p.Oscillators[i].Shape = OscShape(data[m.shape])
 		p.Oscillators[i].PW = data[m.pw]
 		if m.pwmSource >= 0 {
-			p.Oscillators[i].PWMSource = data[m.pwmSource]
+			p.Oscillators[i].PWMSource = ModSource(data[m.pwmSource])
 		}
 		p.Oscillators[i].PWM = data[m.pwm]
 		if m.limitWT >= 0 {
@@ -390,7 +442,7 @@ func ParseSDATA(data []byte) (*Patch, error) {
 	}
 
 	p.Osc2Sync = data[oscSyncIdx]
-	p.OscPitchSource = data[oscPitchSourceIdx]
+	p.OscPitchSource = ModSource(data[oscPitchSourceIdx])
 	p.OscPitchAmount = data[oscPitchAmountIdx]
 
 	// Mixer levels
@@ -411,27 +463,28 @@ func ParseSDATA(data []byte) (*Patch, error) {
 		if i >= len(p.Filters) {
 			break
 		}
-		p.Filters[i].Type = data[m.typ]
+		p.Filters[i].Type = FilterType(data[m.typ])
 		p.Filters[i].Cutoff = data[m.cutoff]
 		p.Filters[i].Res = data[m.res]
 		p.Filters[i].Drive = data[m.drive]
-		p.Filters[i].DriveCurve = data[m.driveCurve]
+		p.Filters[i].DriveCurve = DriveCurve(data[m.driveCurve])
 		p.Filters[i].EnvAmt = data[m.envAmt]
 		p.Filters[i].EnvVel = data[m.envVel]
 		p.Filters[i].Keytrack = data[m.keytrack]
-		p.Filters[i].ModSource = data[m.modSource]
+		p.Filters[i].ModSource = ModSource(data[m.modSource])
 		p.Filters[i].ModAmount = data[m.modAmount]
-		p.Filters[i].FMSource = data[m.fmSource]
+		p.Filters[i].FMSource = FMSource(data[m.fmSource])
 		p.Filters[i].FMAmount = data[m.fmAmount]
 		p.Filters[i].Pan = data[m.pan]
-		p.Filters[i].PanSource = data[m.panSource]
+		p.Filters[i].PanSource = ModSource(data[m.panSource])
 		p.Filters[i].PanAmount = data[m.panAmount]
 	}
 
 	p.FilterRouting = data[filterRoutingIdx]
+	p.Glide = data[glideIdx]
 	p.GlideMode = data[glideModeIdx]
 	p.GlideRate = data[glideRateIdx]
-	p.Unison = data[unisonIdx]
+	p.Unison = unpackUnison(data[unisonIdx])
 	p.UnisonDetune = data[unisonDetuneIdx]
 
 	// Envelopes
@@ -439,7 +492,8 @@ func ParseSDATA(data []byte) (*Patch, error) {
 		if i >= len(p.Envelopes) {
 			break
 		}
-		p.Envelopes[i].Mode = data[m.mode]
+		p.Envelopes[i].Mode = EnvMode(data[m.mode] & 0x1F)
+		p.Envelopes[i].Trigger = EnvTrigger(data[m.mode] >> 5 & 0x03)
 		p.Envelopes[i].Attack = data[m.attack]
 		p.Envelopes[i].AttackLevel = data[m.attackLevel]
 		p.Envelopes[i].Decay = data[m.decay]
@@ -454,7 +508,7 @@ func ParseSDATA(data []byte) (*Patch, error) {
 		if i >= len(p.LFOs) {
 			break
 		}
-		p.LFOs[i].Shape = data[m.shape]
+		p.LFOs[i].Shape = LFOShape(data[m.shape])
 		p.LFOs[i].Speed = data[m.speed]
 		p.LFOs[i].Sync = data[m.sync]
 		p.LFOs[i].Clocked = data[m.clocked]
@@ -469,10 +523,8 @@ func ParseSDATA(data []byte) (*Patch, error) {
 		if i >= len(p.Effects) {
 			break
 		}
-		p.Effects[i].Type = data[m.typ]
+		p.Effects[i].Type = EffectType(data[m.typ])
 		p.Effects[i].Mix = data[m.mix]
-		p.Effects[i].Param1 = data[m.param1]
-		p.Effects[i].Param2 = data[m.param2]
 		// Params 1..14
 		for j := 0; j < len(p.Effects[i].Params); j++ {
 			idx := m.paramsStart + j
@@ -489,8 +541,8 @@ func ParseSDATA(data []byte) (*Patch, error) {
 		if base+2 >= len(data) {
 			break
 		}
-		p.ModMatrix[i].Source = data[base]
-		p.ModMatrix[i].Dest = data[base+1]
+		p.ModMatrix[i].Source = ModSource(data[base])
+		p.ModMatrix[i].Dest = ModDest(data[base+1])
 		p.ModMatrix[i].Amount = data[base+2]
 	}
 
@@ -500,9 +552,9 @@ func ParseSDATA(data []byte) (*Patch, error) {
 		if base+3 >= len(data) {
 			break
 		}
-		p.Modifiers[i].SourceA = data[base]
-		p.Modifiers[i].SourceB = data[base+1]
-		p.Modifiers[i].Operator = data[base+2]
+		p.Modifiers[i].SourceA = ModSource(data[base])
+		p.Modifiers[i].SourceB = ModSource(data[base+1])
+		p.Modifiers[i].Operator = ModOperator(data[base+2])
 		p.Modifiers[i].Constant = data[base+3]
 	}
 
@@ -522,27 +574,25 @@ func ParseSDATA(data []byte) (*Patch, error) {
 	for i := 0; i < len(p.ArpPatternSteps); i++ {
 		idx := arpPatternStepsStartIdx + i
 		if idx < len(data) {
-			p.ArpPatternSteps[i] = data[idx]
+			p.ArpPatternSteps[i] = unpackArpStep(data[idx])
 		}
 	}
 	for i := 0; i < len(p.ArpPatternTiming); i++ {
 		idx := arpPatternTimingStartIdx + i
 		if idx < len(data) {
-			p.ArpPatternTiming[i] = data[idx]
+			p.ArpPatternTiming[i] = unpackArpTiming(data[idx])
 		}
 	}
 
-	// Keep some metadata handy.
-	name := make([]byte, 16)
-	copy(name, data[363:363+16])
-	p.Name = string(bytes.TrimRight(name, "\x00"))
-	p.Category = data[379]
-	p.SubCategory = data[380]
+	// Keep some metadata handy. Trailing spaces are part of the name.
+	p.Name = string(bytes.TrimRight(data[nameIdx:nameIdx+nameLength], "\x00"))
+	p.Category = Category(data[categoryIdx])
+	p.SubCategory = data[subCategoryIdx]
 
 	// Amp and misc fields
 	p.AmpVolume = data[ampVolumeIdx]
 	p.AmpVelocity = data[ampVelocityIdx]
-	p.AmpModSource = data[ampModSourceIdx]
+	p.AmpModSource = ModSource(data[ampModSourceIdx])
 	p.AmpModAmount = data[ampModAmountIdx]
 	p.AmpDrive = p.AmpModAmount
 	p.MasterTune = data[masterTuneIdx]
@@ -552,6 +602,12 @@ func ParseSDATA(data []byte) (*Patch, error) {
 
 func (p *Patch) ToSDATA() ([]byte, error) {
 	data := make([]byte, PatchSize)
+	if p.Raw != nil {
+		if len(p.Raw) != PatchSize {
+			return nil, fmt.Errorf("raw SDATA must be %d bytes, got %d", PatchSize, len(p.Raw))
+		}
+		copy(data, p.Raw)
+	}
 
 	// Map oscillator fields back to SDATA indexes (3.1 SDATA table).
 	for i, m := range oscFieldMapping {
@@ -570,12 +626,12 @@ func (p *Patch) ToSDATA() ([]byte, error) {
 			data[m.keytrack] = osc.Keytrack
 		}
 		data[m.detune] = osc.Detune
-		data[m.fmSource] = osc.FMSource
+		data[m.fmSource] = byte(osc.FMSource)
 		data[m.fm] = osc.FM
-		data[m.shape] = osc.Shape
+		data[m.shape] = byte(osc.Shape)
 		data[m.pw] = osc.PW
 		if m.pwmSource >= 0 {
-			data[m.pwmSource] = osc.PWMSource
+			data[m.pwmSource] = byte(osc.PWMSource)
 		}
 		data[m.pwm] = osc.PWM
 		if m.limitWT >= 0 {
@@ -587,7 +643,7 @@ func (p *Patch) ToSDATA() ([]byte, error) {
 	}
 
 	data[oscSyncIdx] = p.Osc2Sync
-	data[oscPitchSourceIdx] = p.OscPitchSource
+	data[oscPitchSourceIdx] = byte(p.OscPitchSource)
 	data[oscPitchAmountIdx] = p.OscPitchAmount
 
 	// Mixer levels
@@ -609,27 +665,28 @@ func (p *Patch) ToSDATA() ([]byte, error) {
 			break
 		}
 		f := p.Filters[i]
-		data[m.typ] = f.Type
+		data[m.typ] = byte(f.Type)
 		data[m.cutoff] = f.Cutoff
 		data[m.res] = f.Res
 		data[m.drive] = f.Drive
-		data[m.driveCurve] = f.DriveCurve
+		data[m.driveCurve] = byte(f.DriveCurve)
 		data[m.envAmt] = f.EnvAmt
 		data[m.envVel] = f.EnvVel
 		data[m.keytrack] = f.Keytrack
-		data[m.modSource] = f.ModSource
+		data[m.modSource] = byte(f.ModSource)
 		data[m.modAmount] = f.ModAmount
-		data[m.fmSource] = f.FMSource
+		data[m.fmSource] = byte(f.FMSource)
 		data[m.fmAmount] = f.FMAmount
 		data[m.pan] = f.Pan
-		data[m.panSource] = f.PanSource
+		data[m.panSource] = byte(f.PanSource)
 		data[m.panAmount] = f.PanAmount
 	}
 
 	data[filterRoutingIdx] = p.FilterRouting
+	data[glideIdx] = p.Glide
 	data[glideModeIdx] = p.GlideMode
 	data[glideRateIdx] = p.GlideRate
-	data[unisonIdx] = p.Unison
+	data[unisonIdx] = data[unisonIdx]&^unisonMask | p.Unison.pack()
 	data[unisonDetuneIdx] = p.UnisonDetune
 
 	// Envelopes
@@ -638,7 +695,7 @@ func (p *Patch) ToSDATA() ([]byte, error) {
 			break
 		}
 		env := p.Envelopes[i]
-		data[m.mode] = env.Mode
+		data[m.mode] = byte(env.Trigger)&0x03<<5 | byte(env.Mode)&0x1F
 		data[m.attack] = env.Attack
 		data[m.attackLevel] = env.AttackLevel
 		data[m.decay] = env.Decay
@@ -654,7 +711,7 @@ func (p *Patch) ToSDATA() ([]byte, error) {
 			break
 		}
 		lfo := p.LFOs[i]
-		data[m.shape] = lfo.Shape
+		data[m.shape] = byte(lfo.Shape)
 		data[m.speed] = lfo.Speed
 		data[m.sync] = lfo.Sync
 		data[m.clocked] = lfo.Clocked
@@ -670,15 +727,17 @@ func (p *Patch) ToSDATA() ([]byte, error) {
 			break
 		}
 		eff := p.Effects[i]
-		data[m.typ] = eff.Type
+		data[m.typ] = byte(eff.Type)
 		data[m.mix] = eff.Mix
-		data[m.param1] = eff.Param1
-		data[m.param2] = eff.Param2
 		for j := 0; j < len(eff.Params); j++ {
 			idx := m.paramsStart + j
 			if idx >= len(data) {
 				break
 			}
+			// Slots the effect type does not use keep their raw value.
+			if p.Raw != nil && !eff.Type.usesSlot(j) {
+				continue
+			}
 			data[idx] = eff.Params[j]
 		}
 	}
@@ -689,8 +748,8 @@ func (p *Patch) ToSDATA() ([]byte, error) {
 		if base+2 >= len(data) {
 			break
 		}
-		data[base] = mod.Source
-		data[base+1] = mod.Dest
+		data[base] = byte(mod.Source)
+		data[base+1] = byte(mod.Dest)
 		data[base+2] = mod.Amount
 	}
 
@@ -700,9 +759,9 @@ func (p *Patch) ToSDATA() ([]byte, error) {
 		if base+3 >= len(data) {
 			break
 		}
-		data[base] = mod.SourceA
-		data[base+1] = mod.SourceB
-		data[base+2] = mod.Operator
+		data[base] = byte(mod.SourceA)
+		data[base+1] = byte(mod.SourceB)
+		data[base+2] = byte(mod.Operator)
 		data[base+3] = mod.Constant
 	}
 
@@ -722,39 +781,30 @@ func (p *Patch) ToSDATA() ([]byte, error) {
 	for i := 0; i < len(p.ArpPatternSteps); i++ {
 		idx := arpPatternStepsStartIdx + i
 		if idx < len(data) {
-			data[idx] = p.ArpPatternSteps[i]
+			data[idx] = p.ArpPatternSteps[i].pack()
 		}
 	}
 	for i := 0; i < len(p.ArpPatternTiming); i++ {
 		idx := arpPatternTimingStartIdx + i
 		if idx < len(data) {
-			data[idx] = p.ArpPatternTiming[i]
+			data[idx] = data[idx]&^arpTimingMask | p.ArpPatternTiming[i].pack()
 		}
 	}
 
-	// Patch name and metadata preserved/overwritten as before.
-	if p.Name != "" {
-		name := []byte(p.Name)
-		if len(name) > 16 {
-			name = name[:16]
-		}
-		for len(name) < 16 {
-			name = append(name, 0)
-		}
-		copy(data[363:363+16], name)
+	// Patch name and metadata. An unchanged name keeps its original padding;
+	// without raw bytes the name is always written.
+	rawName := data[nameIdx : nameIdx+nameLength]
+	if p.Raw == nil || string(bytes.TrimRight(rawName, "\x00")) != p.Name {
+		copy(rawName, nameBytes(p.Name))
 	}
 
-	if p.Category != 0 {
-		data[379] = p.Category
-	}
-	if p.SubCategory != 0 {
-		data[380] = p.SubCategory
-	}
+	data[categoryIdx] = byte(p.Category)
+	data[subCategoryIdx] = p.SubCategory
 
 	// Amp and misc fields
 	data[ampVolumeIdx] = p.AmpVolume
 	data[ampVelocityIdx] = p.AmpVelocity
-	data[ampModSourceIdx] = p.AmpModSource
+	data[ampModSourceIdx] = byte(p.AmpModSource)
 	data[ampModAmountIdx] = p.AmpModAmount
 	data[masterTuneIdx] = p.MasterTune
 
@@ -770,26 +820,72 @@ func (p *Patch) ToSNDD(deviceID byte, bank byte, program byte) ([]byte, error) {
 		return nil, err
 	}
 
+	out := snddMessage(deviceID, bank, program, sdata)
+
+	dumpBytes(out, "sent_sndd.txt")
+
+	return out, nil
+}
+
+// snddMessage frames SDATA as a Sound Dump for the given location.
+func snddMessage(deviceID byte, bank byte, program byte, sdata []byte) []byte {
 	out := []byte{0xF0, 0x3E, 0x13, deviceID, 0x10, bank, program}
 	out = append(out, sdata...)
+	return append(out, sysexChecksum(sdata), 0xF7)
+}
 
+// sysexChecksum sums the data bytes truncated to 7 bits (spec section 1).
+func sysexChecksum(data []byte) byte {
 	var chk byte
-	for _, b := range sdata {
+	for _, b := range data {
 		chk = (chk + b) & 0x7F
 	}
-	out = append(out, chk, 0xF7)
-
-	dumpBytes(out, "sent_sndd.txt")
-
-	return out, nil
+	return chk
 }
 
 type Blofeld struct {
+	transport Transport
+	inbox     *dispatcher
+
+	mu    sync.Mutex
 	devID byte
-	out   drivers.Out
 }
 
-func OpenBlofeld(devID byte, portIndex int) (*Blofeld, func(), error) {
+// NewBlofeld returns a Blofeld that talks over t.
+func NewBlofeld(devID byte, t Transport) *Blofeld {
+	return &Blofeld{devID: devID, transport: t, inbox: newDispatcher(t)}
+}
+
+// DeviceID returns the device ID requests are addressed to. Read it once per
+// request so the SysEx frame and its reply matcher agree.
+func (b *Blofeld) DeviceID() byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.devID
+}
+
+// SetDeviceID changes the device ID used for later requests, e.g. after the
+// global settings have been changed.
+func (b *Blofeld) SetDeviceID(devID byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.devID = devID
+}
+
+// Subscribe calls fn with every SysEx message from the Blofeld that does not
+// answer a request, e.g. the SNDP messages sent while the user turns a knob,
+// until unsubscribe is called. fn must not keep msg after it returns.
+func (b *Blofeld) Subscribe(fn func(msg []byte)) (unsubscribe func(), err error) {
+	return b.inbox.subscribe(fn)
+}
+
+// Close stops listening to the Blofeld.
+func (b *Blofeld) Close() {
+	b.inbox.close()
+}
+
+// OpenBlofeld opens the MIDI output and input ports with the given indices.
+func OpenBlofeld(devID byte, portIndex int, inPortIndex int) (*Blofeld, func(), error) {
 	outs, err := drivers.Outs()
 	if err != nil {
 		return nil, nil, err
@@ -799,30 +895,33 @@ func OpenBlofeld(devID byte, portIndex int) (*Blofeld, func(), error) {
 		return nil, nil, fmt.Errorf("output port index %d out of range", portIndex)
 	}
 
+	ins, err := drivers.Ins()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if inPortIndex < 0 || inPortIndex >= len(ins) {
+		return nil, nil, fmt.Errorf("input port index %d out of range", inPortIndex)
+	}
+
 	out := outs[portIndex]
 	if err := out.Open(); err != nil {
 		return nil, nil, err
 	}
 
+	blo := NewBlofeld(devID, &rtmidiTransport{in: ins[inPortIndex], out: out})
 	closer := func() {
+		blo.Close()
 		_ = out.Close()
 		drivers.Close()
 	}
 	log.Println("Opened Blofeld MIDI output port", devID, out.String())
-	return &Blofeld{
-		devID: devID,
-		out:   out,
-	}, closer, nil
+	return blo, closer, nil
 }
 
-// Send transmits a MIDI message to the Blofeld output port.
+// Send transmits a MIDI message to the Blofeld.
 func (b *Blofeld) Send(msg midi.Message) error {
-	if !b.out.IsOpen() {
-		if err := b.out.Open(); err != nil {
-			return err
-		}
-	}
-	return b.out.Send(msg.Bytes())
+	return b.transport.Send(msg.Bytes())
 }
 
 // SendSysEx transmits a raw SysEx payload.
@@ -831,7 +930,7 @@ func (b *Blofeld) SendSysEx(data []byte) error {
 }
 
 // RequestPatchDump asks Blofeld for a single program and waits for SNDD.
-func (b *Blofeld) RequestPatchDump(inPort drivers.In, bank string, program int) (*Patch, byte, error) {
+func (b *Blofeld) RequestPatchDump(bank string, program int) (*Patch, byte, error) {
 	bankByte, err := bankToByte(bank)
 	if err != nil {
 		return nil, 0, err
@@ -842,79 +941,107 @@ func (b *Blofeld) RequestPatchDump(inPort drivers.In, bank string, program int)
 	}
 	progByte := byte(program - 1) // Blofeld expects 0–127
 
-	msgCh := make(chan midi.Message, 1)
+	return b.requestSound(bankByte, progByte)
+}
 
-	stop, err := midi.ListenTo(inPort, func(msg midi.Message, _ int32) {
-		if len(msg) > 0 && msg[0] == 0xF0 {
-			select {
-			case msgCh <- msg:
-			default:
-			}
-		}
-	}, midi.UseSysEx(), midi.SysExBufferSize(2048))
+// RequestEditBuffer reads the sound currently loaded in the Sound Mode Edit
+// Buffer (location 7F 00). Stored programs are not touched.
+func (b *Blofeld) RequestEditBuffer() (*Patch, byte, error) {
+	return b.requestSound(editBufferBank, 0x00)
+}
+
+// RequestInstrument reads the edit buffer of Multi Mode instrument 1–16
+// (locations 7F 00..7F 0F). Instrument 1 shares its location with the Sound
+// Mode Edit Buffer.
+func (b *Blofeld) RequestInstrument(instrument int) (*Patch, byte, error) {
+	instByte, err := instrumentToByte(instrument)
+	if err != nil {
+		return nil, 0, err
+	}
+	return b.requestSound(editBufferBank, instByte)
+}
+
+func (b *Blofeld) requestSound(bankByte byte, progByte byte) (*Patch, byte, error) {
+	devID := b.DeviceID()
+	log.Printf("Requesting patch dump from device ID 0x%02X", devID)
+	req := []byte{0xF0, 0x3E, 0x13, devID, 0x00, bankByte, progByte, 0xF7}
+	msg, err := b.requestSysEx(req, replyTo(devID, 0x10, bankByte, progByte))
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to listen for patch dump: %w", err)
+		return nil, 0, fmt.Errorf("failed to request patch dump: %w", err)
 	}
-	defer stop()
 
-	log.Printf("Requesting patch dump from device ID 0x%02X", b.devID)
-	req := []byte{0xF0, 0x3E, 0x13, b.devID, 0x00, bankByte, progByte, 0xF7}
+	return parseSNDD(msg)
+}
+
+// requestSysEx sends a request and waits for the first SysEx reply accepted
+// by match, see replyTo. Other messages received meanwhile are left to other
+// requests and the subscribers.
+func (b *Blofeld) requestSysEx(req []byte, match func(msg []byte) bool) (midi.Message, error) {
+	msgCh, cancel, err := b.inbox.expect(match, 1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for reply: %w", err)
+	}
+	defer cancel()
+
 	log.Println("Sending SysEx request")
 	if err := b.SendSysEx(req); err != nil {
-		return nil, 0, fmt.Errorf("failed to request patch dump: %w", err)
+		return nil, err
 	}
 
 	select {
 	case msg := <-msgCh:
 		log.Println("Received SysEx message")
-		patch, respDevID, parseErr := parseSNDD(msg)
-		if parseErr != nil {
-			return nil, 0, parseErr
-		}
-		return patch, respDevID, nil
+		return msg, nil
 	case <-time.After(5 * time.Second):
-		log.Println("Timed out waiting for patch dump")
+		log.Println("Timed out waiting for reply")
 	}
 
-	return nil, 0, errors.New("timed out waiting for patch dump")
+	return nil, errors.New("timed out waiting for reply")
 }
 
 func parseSNDD(msg midi.Message) (*Patch, byte, error) {
 
 	dumpBytes(msg, "received_sysex.txt")
 
+	sdata, err := checkSNDD(msg)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	dumpBytes(sdata, "received_sdata.txt")
+
+	patch, err := ParseSDATA(sdata)
+	return patch, msg[3], err
+}
+
+// checkSNDD validates the framing and checksum of a Sound Dump and returns
+// its SDATA payload.
+func checkSNDD(msg []byte) ([]byte, error) {
 	if len(msg) != PatchSize+9 {
-		return nil, 0, fmt.Errorf("unexpected dump size %d (want %d)", len(msg), PatchSize+9)
+		return nil, fmt.Errorf("unexpected dump size %d (want %d)", len(msg), PatchSize+9)
 	}
 
 	if msg[0] != 0xF0 || msg[len(msg)-1] != 0xF7 {
-		return nil, 0, errors.New("message is not a SysEx frame")
+		return nil, errors.New("message is not a SysEx frame")
 	}
 
 	if msg[1] != 0x3E || msg[2] != 0x13 {
-		return nil, 0, errors.New("not a Waldorf Blofeld SysEx")
+		return nil, errors.New("not a Waldorf Blofeld SysEx")
 	}
 
 	if msg[4] != 0x10 {
-		return nil, 0, fmt.Errorf("unexpected message type 0x%02X (expected SNDD 0x10)", msg[4])
+		return nil, fmt.Errorf("unexpected message type 0x%02X (expected SNDD 0x10)", msg[4])
 	}
 
 	sdata := msg[7 : 7+PatchSize]
 	checksum := msg[7+PatchSize]
 
-	var chk byte
-	for _, b := range sdata {
-		chk = (chk + b) & 0x7F
-	}
-
+	chk := sysexChecksum(sdata)
 	if checksum != 0x7F && chk != checksum {
-		return nil, 0, fmt.Errorf("checksum mismatch: expected 0x%02X got 0x%02X", chk, checksum)
+		return nil, fmt.Errorf("checksum mismatch: expected 0x%02X got 0x%02X", chk, checksum)
 	}
 
-	dumpBytes(sdata, "received_sdata.txt")
-
-	patch, err := ParseSDATA(sdata)
-	return patch, msg[3], err
+	return sdata, nil
 }
 
 // SendPatch transmits a patch to the given bank/program.
@@ -928,13 +1055,96 @@ func (b *Blofeld) SendPatch(bank string, program int, p *Patch, devID byte) erro
 	}
 	progByte := byte(program - 1)
 
+	if err := b.sendSound(bankByte, progByte, p, devID); err != nil {
+		return fmt.Errorf("failed to send patch to bank %s program %d: %w", bank, program, err)
+	}
+	return nil
+}
+
+// SendEditBuffer loads a patch into the Sound Mode Edit Buffer (location
+// 7F 00) so it can be auditioned without overwriting a stored program.
+func (b *Blofeld) SendEditBuffer(p *Patch) error {
+	if err := b.sendSound(editBufferBank, 0x00, p, b.DeviceID()); err != nil {
+		return fmt.Errorf("failed to send patch to edit buffer: %w", err)
+	}
+	return nil
+}
+
+// SendInstrument loads a patch into the edit buffer of Multi Mode
+// instrument 1–16 without storing it.
+func (b *Blofeld) SendInstrument(instrument int, p *Patch) error {
+	instByte, err := instrumentToByte(instrument)
+	if err != nil {
+		return err
+	}
+	if err := b.sendSound(editBufferBank, instByte, p, b.DeviceID()); err != nil {
+		return fmt.Errorf("failed to send patch to instrument %d: %w", instrument, err)
+	}
+	return nil
+}
+
+// SaveEditBuffer stores the current edit buffer sound to the given
+// bank/program. This is the explicit step that overwrites a stored program.
+func (b *Blofeld) SaveEditBuffer(bank string, program int) error {
+	return b.SaveInstrument(1, bank, program)
+}
+
+// SaveInstrument stores the edit buffer of Multi Mode instrument 1–16 to the
+// given bank/program.
+func (b *Blofeld) SaveInstrument(instrument int, bank string, program int) error {
+	p, _, err := b.RequestInstrument(instrument)
+	if err != nil {
+		return fmt.Errorf("failed to read instrument %d: %w", instrument, err)
+	}
+	return b.SendPatch(bank, program, p, b.DeviceID())
+}
+
+func (b *Blofeld) sendSound(bankByte byte, progByte byte, p *Patch, devID byte) error {
 	payload, err := p.ToSNDD(devID, bankByte, progByte)
 	if err != nil {
 		return fmt.Errorf("failed to build SNDD payload: %w", err)
 	}
 
-	if err := b.SendSysEx(payload); err != nil {
-		return fmt.Errorf("failed to send patch to bank %s program %d: %w", bank, program, err)
+	return b.SendSysEx(payload)
+}
+
+// SetParameter changes a single SDATA parameter via SNDP (spec 2.13). The
+// location is 0 for the Sound Mode Edit Buffer or 0–15 for the Multi Mode
+// instrument edit buffers. The change is audible immediately and does not
+// touch any stored program.
+func (b *Blofeld) SetParameter(location byte, index int, value byte) error {
+	msg, err := sndpMessage(b.DeviceID(), location, index, value)
+	if err != nil {
+		return err
+	}
+
+	if err := b.SendSysEx(msg); err != nil {
+		return fmt.Errorf("failed to set parameter %d at location %d: %w", index, location, err)
 	}
 	return nil
 }
+
+// SetInstrumentParameter changes a single SDATA parameter in the edit buffer
+// of Multi Mode instrument 1–16.
+func (b *Blofeld) SetInstrumentParameter(instrument int, index int, value byte) error {
+	instByte, err := instrumentToByte(instrument)
+	if err != nil {
+		return err
+	}
+	return b.SetParameter(instByte, index, value)
+}
+
+func sndpMessage(devID byte, location byte, index int, value byte) ([]byte, error) {
+	if location > 0x0F {
+		return nil, fmt.Errorf("location must be in range 0–15, got %d", location)
+	}
+	if index < 0 || index >= PatchSize {
+		return nil, fmt.Errorf("parameter index must be in range 0–%d, got %d", PatchSize-1, index)
+	}
+	if value > 0x7F {
+		return nil, fmt.Errorf("parameter value must be in range 0–127, got %d", value)
+	}
+
+	// The index is split into a high byte (HH) and a 7-bit low byte (PP).
+	return []byte{0xF0, 0x3E, 0x13, devID, 0x20, location, byte(index >> 7), byte(index & 0x7F), value, 0xF7}, nil
+}
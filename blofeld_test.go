@@ -1,14 +1,22 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"math/rand"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 )
 
 func TestPatchSerialization(t *testing.T) {
 	p := &Patch{
-		Name: "Test Patch",
+		Name: NormalizeName("Test Patch"),
 	}
 
 	p.MixOsc1 = 10
@@ -45,12 +53,12 @@ func TestPatchSerialization(t *testing.T) {
 	p.ArpPatternLength = 16
 	p.ArpTempo = 100
 	for i := range p.ArpPatternSteps {
-		p.ArpPatternSteps[i] = byte(i)
-		p.ArpPatternTiming[i] = byte(127 - i)
+		p.ArpPatternSteps[i] = ArpStep{Kind: ArpStepKind(i % 8), Glide: i%2 == 1, Accent: byte(i % 8)}
+		p.ArpPatternTiming[i] = ArpTiming{Length: byte(i % 8), Timing: byte(7 - i%8)}
 	}
 
-	p.Effects[0] = Effect{Type: 1, Param1: 2, Param2: 3}
-	p.Effects[1] = Effect{Type: 4, Param1: 5, Param2: 6}
+	p.Effects[0] = Effect{Type: 1, Params: [14]byte{2, 3}}
+	p.Effects[1] = Effect{Type: 4, Params: [14]byte{5, 6}}
 
 	p.AmpVolume = 100
 	p.AmpModAmount = 64
@@ -58,7 +66,7 @@ func TestPatchSerialization(t *testing.T) {
 	p.AmpPan = 0
 	p.MasterTune = 58
 
-	p.RandomizeOscillators()
+	p.RandomizeOscillators(1)
 
 	bytes, err := p.ToSDATA()
 	if err != nil {
@@ -70,6 +78,7 @@ func TestPatchSerialization(t *testing.T) {
 	if err != nil {
 		t.Fatalf("failed to parse SDATA: %v", err)
 	}
+	p2.Raw = nil // p was built from scratch; raw bytes are covered by TestSDATAByteExact
 
 	if p2.Name != p.Name {
 		t.Errorf("expected patch name %q, got %q", p.Name, p2.Name)
@@ -137,9 +146,799 @@ func TestPatchSerialization(t *testing.T) {
 			continue
 		}
 
-		if p1Map[k] != p2Map[k] {
+		if !reflect.DeepEqual(p1Map[k], p2Map[k]) {
 			t.Errorf("mismatch for key %q: expected %v, got %v", k, p1Map[k], p2Map[k])
 		}
 	}
 
 }
+
+func TestSNDPMessage(t *testing.T) {
+	msg, err := sndpMessage(0x00, 0x00, 300, 42)
+	if err != nil {
+		t.Fatalf("failed to build SNDP message: %v", err)
+	}
+
+	want := []byte{0xF0, 0x3E, 0x13, 0x00, 0x20, 0x00, 0x02, 0x2C, 42, 0xF7}
+	if string(msg) != string(want) {
+		t.Errorf("expected % X, got % X", want, msg)
+	}
+
+	if _, err := sndpMessage(0x00, 0x10, 0, 0); err == nil {
+		t.Errorf("expected error for location 16")
+	}
+	if _, err := sndpMessage(0x00, 0x00, PatchSize, 0); err == nil {
+		t.Errorf("expected error for index %d", PatchSize)
+	}
+}
+
+func TestGlobalSettingsRoundTrip(t *testing.T) {
+	g := &GlobalSettings{
+		MultiMode:   1,
+		MIDIChannel: 5,
+		DeviceID:    0x12,
+		MasterTune:  64,
+		Transpose:   64,
+		VelCurve:    3,
+		ControlW:    5,
+		ControlZ:    120,
+		Volume:      100,
+	}
+	g.Sounds[15] = GlobalSound{Bank: 7, Sound: 127}
+
+	g2, devID, err := parseGLBD(g.ToGLBD(0x12))
+	if err != nil {
+		t.Fatalf("failed to parse GLBD: %v", err)
+	}
+	if devID != 0x12 {
+		t.Errorf("expected device ID 0x12, got 0x%02X", devID)
+	}
+	if len(g2.Raw) != GlobalSize {
+		t.Errorf("expected %d raw bytes, got %d", GlobalSize, len(g2.Raw))
+	}
+	g2.Raw = nil
+	if !reflect.DeepEqual(g2, g) {
+		t.Errorf("expected %+v, got %+v", g, g2)
+	}
+
+	if ch, ok := g2.Channel(); !ok || ch != 4 {
+		t.Errorf("expected channel 4, got %d (ok=%v)", ch, ok)
+	}
+}
+
+func TestGlobalSettingsReadModifyWrite(t *testing.T) {
+	gdata := make([]byte, GlobalSize)
+	gdata[globalChannelIdx] = 5
+	gdata[globalMasterTuneIdx] = 64
+	gdata[globalTransposeIdx] = 64
+	gdata[globalPopupTimeIdx] = 20
+	gdata[42] = 0x33 // reserved
+	gdata[70] = 0x11 // reserved
+
+	g, err := ParseGDATA(gdata)
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	if err := g.Validate(); err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+
+	g.Volume = 90
+	out := g.ToGDATA()
+	if out[42] != 0x33 || out[70] != 0x11 || out[globalVolumeIdx] != 90 {
+		t.Errorf("reserved bytes or volume lost: % X", out)
+	}
+
+	g.DeviceID = 127
+	g.Volume = 200
+	g.MIDIChannel = 17
+	err = g.Validate()
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || len(errs) != 3 {
+		t.Fatalf("expected 3 invalid fields, got %v", err)
+	}
+	if errs[0].Path != "midi_channel" || errs[1].Path != "device_id" || errs[2].Path != "volume" {
+		t.Errorf("unexpected errors %v", errs)
+	}
+
+	g.Raw = g.Raw[:10]
+	if err := g.Validate(); err == nil {
+		t.Errorf("expected an error for truncated raw GDATA")
+	}
+}
+
+func TestArchivedSoundValidation(t *testing.T) {
+	p := &Patch{Name: "Archived"}
+	frame, err := p.ToSNDD(0x00, 2, 11)
+	if err != nil {
+		t.Fatalf("failed to build SNDD: %v", err)
+	}
+
+	s := newArchivedSound(frame)
+	if s.Error != "" || s.Bank != "C" || s.Program != 12 || s.Name != NormalizeName("Archived") {
+		t.Errorf("unexpected archived sound %+v", s)
+	}
+
+	bad := append([]byte(nil), frame...)
+	bad[len(bad)-2] = (bad[len(bad)-2] + 1) & 0x3F
+	if s := newArchivedSound(bad); s.Error == "" {
+		t.Errorf("expected checksum error for corrupted frame")
+	}
+}
+
+func TestSyxRoundTrip(t *testing.T) {
+	sounds := []SyxSound{
+		{Bank: 0, Program: 0, Patch: &Patch{Name: "First"}},
+		{Bank: 0, Program: 1, Patch: &Patch{Name: "Second"}},
+	}
+
+	// Frames of other devices may share the SNDD IDM byte.
+	var buf bytes.Buffer
+	buf.Write([]byte{0xF0, 0x43, 0x10, 0x00, 0x10, 0x00, 0x01, 0xF7})
+	if err := WriteSyx(&buf, 0x00, sounds); err != nil {
+		t.Fatalf("failed to write syx: %v", err)
+	}
+
+	read, err := ReadSyx(&buf)
+	if err != nil {
+		t.Fatalf("failed to read syx: %v", err)
+	}
+	if len(read) != 2 || read[1].Patch.Name != NormalizeName("Second") || read[1].Program != 1 {
+		t.Fatalf("unexpected sounds read back: %+v", read)
+	}
+
+	if err := RelocateSounds(read, 1, 127); err != nil {
+		t.Fatalf("failed to relocate: %v", err)
+	}
+	if read[0].Bank != 1 || read[0].Program != 127 || read[1].Bank != 2 || read[1].Program != 0 {
+		t.Errorf("unexpected locations after relocation: %+v", read)
+	}
+
+	if err := RelocateSounds(read, 7, 127); err == nil {
+		t.Errorf("expected error when relocating past bank H")
+	}
+}
+
+func TestEnumJSON(t *testing.T) {
+	m := ModulationMatrix{Source: 1, Amount: 64, Dest: 20}
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	want := `{"source":"LFO 1","amount":64,"dest":"F1 Cutoff"}`
+	if string(data) != want {
+		t.Fatalf("got %s, want %s", data, want)
+	}
+
+	var got ModulationMatrix
+	if err := json.Unmarshal([]byte(`{"source":"lfo_1","amount":64,"dest":20}`), &got); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	if got != m {
+		t.Fatalf("got %+v, want %+v", got, m)
+	}
+
+	var shape OscShape
+	if err := json.Unmarshal([]byte(`"no such wave"`), &shape); err == nil {
+		t.Fatal("expected an error for an unknown shape")
+	}
+}
+
+func TestDisplayRoundTrip(t *testing.T) {
+	for raw := 0; raw < 128; raw++ {
+		p := &Patch{}
+		p.Oscillators[0].Octave = byte(raw)
+		p.Oscillators[1].Pitch = byte(raw)
+		p.Oscillators[2].Keytrack = byte(raw)
+		p.Filters[1].Pan = byte(raw)
+		p.ModMatrix[3].Amount = byte(raw)
+
+		data, err := p.MarshalDisplay()
+		if err != nil {
+			t.Fatalf("raw %d: failed to marshal: %v", raw, err)
+		}
+
+		got := &Patch{}
+		if err := got.UnmarshalDisplay(data); err != nil {
+			t.Fatalf("raw %d: failed to unmarshal: %v", raw, err)
+		}
+		if !reflect.DeepEqual(got, p) {
+			t.Fatalf("raw %d: display round trip mismatch\n%s", raw, data)
+		}
+	}
+}
+
+func TestDisplayUnits(t *testing.T) {
+	p := &Patch{}
+	err := p.UnmarshalDisplay([]byte(`{"oscillators":[{"octave":"8'","pitch":-12,"keytrack":100}],"filters":[{"pan":-64}]}`))
+	if err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	osc := p.Oscillators[0]
+	if osc.Octave != 64 || osc.Pitch != 52 || osc.Keytrack != 96 || p.Filters[0].Pan != 0 {
+		t.Fatalf("unexpected raw values: %+v, filter pan %d", osc, p.Filters[0].Pan)
+	}
+
+	if err := p.UnmarshalDisplay([]byte(`{"filters":[{"env_amt":64}]}`)); err == nil {
+		t.Fatal("expected an error for an out-of-range amount")
+	}
+}
+
+// documentedSDATA returns the non-reserved indices of the SDATA table in the
+// embedded SysEx documentation (section 3.1).
+func documentedSDATA(t *testing.T) []int {
+	start := strings.Index(sysexDoc, "3.1 SDATA")
+	end := strings.Index(sysexDoc, "3.2 GDATA")
+	if start < 0 || end < start {
+		t.Fatal("SDATA table not found in the SysEx documentation")
+	}
+
+	var indices []int
+	for _, line := range strings.Split(sysexDoc[start:end], "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[1] == "reserved" {
+			continue
+		}
+		idx, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		indices = append(indices, idx)
+	}
+	return indices
+}
+
+func TestSDATACoverage(t *testing.T) {
+	indices := documentedSDATA(t)
+	if len(indices) < 300 {
+		t.Fatalf("only %d documented SDATA indices found", len(indices))
+	}
+
+	const value = 65 // valid for every parameter that is not a switch, and 'A' as a name char
+	for _, idx := range indices {
+		data := make([]byte, PatchSize)
+		data[idx] = value
+
+		p, err := ParseSDATA(data)
+		if err != nil {
+			t.Fatalf("index %d: failed to parse: %v", idx, err)
+		}
+		p.Raw = nil // serialize from the mapped fields alone
+		out, err := p.ToSDATA()
+		if err != nil {
+			t.Fatalf("index %d: failed to serialize: %v", idx, err)
+		}
+		if out[idx] != value {
+			t.Errorf("SDATA index %d is not mapped by Patch (got %d after round trip)", idx, out[idx])
+		}
+	}
+}
+
+func TestPackedFields(t *testing.T) {
+	data := make([]byte, PatchSize)
+	data[unisonIdx] = 0x31                    // 4 voices, mono
+	data[envelopeFieldMapping[1].mode] = 0x21 // single trigger, ADS1DS2R
+	data[arpPatternStepsStartIdx] = 0x6D      // chord, glide, accent 5
+	data[arpPatternTimingStartIdx] = 0x52     // length 5, timing 2
+
+	p, err := ParseSDATA(data)
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+
+	if p.Unison != (Unison{Voices: 3, Mono: true}) {
+		t.Errorf("unexpected unison %+v", p.Unison)
+	}
+	if env := p.Envelopes[1]; env.Mode != 1 || env.Trigger != 1 {
+		t.Errorf("unexpected envelope mode %v, trigger %v", env.Mode, env.Trigger)
+	}
+	if step := p.ArpPatternSteps[0]; step != (ArpStep{Kind: 6, Glide: true, Accent: 5}) {
+		t.Errorf("unexpected arp step %+v", step)
+	}
+	if timing := p.ArpPatternTiming[0]; timing != (ArpTiming{Length: 5, Timing: 2}) {
+		t.Errorf("unexpected arp timing %+v", timing)
+	}
+
+	out, err := p.ToSDATA()
+	if err != nil {
+		t.Fatalf("failed to serialize: %v", err)
+	}
+	if !bytes.Equal(out, data) {
+		t.Errorf("packed fields did not serialize back into the same bytes")
+	}
+}
+
+func TestSDATAByteExact(t *testing.T) {
+	// Every byte random, including reserved ones and unused bits of packed
+	// parameters, as left behind by other firmware versions.
+	rng := rand.New(rand.NewSource(1))
+	sdata := make([]byte, PatchSize)
+	for i := range sdata {
+		sdata[i] = byte(rng.Intn(128))
+	}
+	dumps := map[string][]byte{"random": sdata}
+
+	// The SNDD dumps in testdata/*.syx. Both are synthetic, not captured from
+	// hardware: init.syx is initSDATA in the edit buffer and bank.syx holds
+	// random, all-127 and wildcard-checksum frames. Dumps from a real
+	// Blofeld placed next to them are checked as well.
+	files, err := filepath.Glob(filepath.Join("testdata", "*.syx"))
+	if err != nil {
+		t.Fatalf("failed to list dumps: %v", err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no dumps found in testdata/*.syx")
+	}
+	for _, file := range files {
+		sounds, err := ReadSyxFile(file)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		for i, s := range sounds {
+			dumps[fmt.Sprintf("%s #%d", file, i+1)] = s.Patch.Raw
+		}
+	}
+
+	for name, data := range dumps {
+		p, err := ParseSDATA(data)
+		if err != nil {
+			t.Fatalf("%s: failed to parse: %v", name, err)
+		}
+
+		// Through JSON and back, as an MCP client would send it.
+		asJson, err := json.Marshal(p)
+		if err != nil {
+			t.Fatalf("%s: failed to marshal: %v", name, err)
+		}
+		var edited Patch
+		if err := json.Unmarshal(asJson, &edited); err != nil {
+			t.Fatalf("%s: failed to unmarshal: %v", name, err)
+		}
+
+		out, err := edited.ToSDATA()
+		if err != nil {
+			t.Fatalf("%s: failed to serialize: %v", name, err)
+		}
+		for i := range data {
+			if out[i] != data[i] {
+				t.Errorf("%s: index %d changed from %d to %d", name, i, data[i], out[i])
+			}
+		}
+
+		// An edit only touches its own byte.
+		edited.Filters[0].Cutoff = data[78] ^ 0x01
+		out, err = edited.ToSDATA()
+		if err != nil {
+			t.Fatalf("%s: failed to serialize: %v", name, err)
+		}
+		for i := range data {
+			if i != 78 && out[i] != data[i] {
+				t.Errorf("%s: editing the cutoff changed index %d", name, i)
+			}
+		}
+	}
+}
+
+func TestEffectNamedParameters(t *testing.T) {
+	var e Effect
+	if err := json.Unmarshal([]byte(`{"type":"Reverb","mix":40,"parameters":{"decay":90,"damping":10}}`), &e); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	if e.Type != EffectReverb || e.Params[2] != 90 || e.Params[8] != 10 {
+		t.Fatalf("unexpected effect %+v", e)
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	var got Effect
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("failed to unmarshal %s: %v", data, err)
+	}
+	if got != e {
+		t.Fatalf("round trip mismatch: got %+v, want %+v", got, e)
+	}
+	if bytes.Contains(data, []byte(`"params"`)) || bytes.Contains(data, []byte(`"param1"`)) {
+		t.Errorf("raw slots emitted next to the named parameters: %s", data)
+	}
+
+	// Raw slots from older JSON are still read, and must agree with the
+	// named parameters when both are given.
+	legacy := `{"type":"Delay","params":[0,0,0,0,70,0,0,0,0,0,0,0,0,0]}`
+	if err := json.Unmarshal([]byte(legacy), &got); err != nil || got.Params[4] != 70 {
+		t.Errorf("legacy params not read: %+v, %v", got, err)
+	}
+	agreeing := `{"type":"Delay","params":[0,0,0,0,70,0,0,0,0,0,0,0,0,0],"parameters":{"feedback":70}}`
+	if err := json.Unmarshal([]byte(agreeing), &got); err != nil {
+		t.Errorf("agreeing params rejected: %v", err)
+	}
+
+	for _, bad := range []string{
+		`{"type":"Reverb","parameters":{"speed":10}}`,
+		`{"type":"Overdrive","parameters":{"curve":12}}`,
+		`{"type":"Flanger","parameters":{"polarity":"sideways"}}`,
+		`{"type":"Delay","params":[0,0,0,0,50,0,0,0,0,0,0,0,0,0],"parameters":{"feedback":70}}`,
+	} {
+		if err := json.Unmarshal([]byte(bad), &got); err == nil {
+			t.Errorf("expected an error for %s", bad)
+		}
+	}
+
+	current, err := ParseSDATA(initSDATA())
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	if _, err := effectUpdates(current, 0, EffectReverb, nil); err == nil {
+		t.Error("expected an error for a reverb on FX1")
+	}
+
+	// A reverb value left in the slot of the Clk.Delay length (max 29).
+	current.Effects[1].Params[10] = 90
+	if _, err := effectUpdates(current, 1, EffectClkDelay, nil); err == nil {
+		t.Error("expected an error for an inherited length of 90")
+	}
+	if _, err := effectUpdates(current, 1, EffectClkDelay, map[string]json.RawMessage{"length": json.RawMessage("10")}); err != nil {
+		t.Errorf("failed to build updates with a given length: %v", err)
+	}
+
+	updates, err := effectUpdates(current, 1, EffectDelay, map[string]json.RawMessage{"feedback": json.RawMessage("70")})
+	if err != nil {
+		t.Fatalf("failed to build updates: %v", err)
+	}
+	want := []paramUpdate{{144, byte(EffectDelay)}, {150, 70}}
+	if !reflect.DeepEqual(updates, want) {
+		t.Errorf("got updates %v, want %v", updates, want)
+	}
+}
+
+func TestPatchValidate(t *testing.T) {
+	data := initSDATA()
+	p, err := ParseSDATA(data)
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	if err := p.Validate(); err != nil {
+		t.Fatalf("expected a valid patch, got %v", err)
+	}
+
+	p.Oscillators[2].Shape = 5
+	p.Oscillators[0].Octave = 20
+	p.Filters[1].Type = 12
+	p.LFOs[0].Shape = 6
+	p.Effects[0].Type = EffectReverb
+	p.AmpVolume = 200
+
+	err = p.Validate()
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected ValidationErrors, got %v", err)
+	}
+
+	var paths []string
+	for _, e := range errs {
+		paths = append(paths, e.Path)
+	}
+	want := []string{"oscillators[0].octave", "oscillators[2].shape", "filters[1].type", "effects[0].type", "lfos[0].shape", "amp_volume"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("got invalid fields %v, want %v", paths, want)
+	}
+}
+
+func TestNormalizeName(t *testing.T) {
+	tests := map[string]string{
+		"Bass":                   "Bass            ",
+		"A very long sound name": "A very long soun",
+		"Café\tPad":              "Cafe Pad        ",
+		"Trailing  ":             "Trailing        ",
+		"日本":                     "??              ",
+	}
+	for in, want := range tests {
+		if got := NormalizeName(in); got != want {
+			t.Errorf("NormalizeName(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	// A name stored with zero padding keeps it as long as it is not edited.
+	data := make([]byte, PatchSize)
+	copy(data[nameIdx:], "Init")
+	p, err := ParseSDATA(data)
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	out, _ := p.ToSDATA()
+	if !bytes.Equal(out, data) {
+		t.Errorf("unchanged name was rewritten: %q", out[nameIdx:nameIdx+nameLength])
+	}
+
+	p.Name = "Init 2"
+	out, _ = p.ToSDATA()
+	if got := string(out[nameIdx : nameIdx+nameLength]); got != "Init 2          " {
+		t.Errorf("renamed sound stored as %q", got)
+	}
+
+	// Without raw bytes an empty name is stored as spaces, not NUL bytes.
+	out, _ = (&Patch{}).ToSDATA()
+	if got := string(out[nameIdx : nameIdx+nameLength]); got != NormalizeName("") {
+		t.Errorf("empty name stored as %q", got)
+	}
+}
+
+func TestDiffPatches(t *testing.T) {
+	a, err := ParseSDATA(make([]byte, PatchSize))
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	a.Effects[1].Type = EffectReverb
+
+	b, _ := ParseSDATA(make([]byte, PatchSize))
+	b.Effects[1].Type = EffectReverb
+	b.Oscillators[0].Octave = 64
+	b.ModMatrix[10].Amount = 70
+	b.ModMatrix[2].Amount = 60
+	b.Effects[1].Params[2] = 90 // reverb decay
+	b.Raw[0] = 1                // reserved, not a field
+
+	changes, err := DiffPatches(a, b)
+	if err != nil {
+		t.Fatalf("failed to diff: %v", err)
+	}
+
+	var got []string
+	for _, c := range changes {
+		got = append(got, fmt.Sprintf("%s: %v -> %v", c.Path, c.Old, c.New))
+	}
+	want := []string{
+		"effects[1].parameters.decay: 0 -> 90",
+		"mod_matrix[2].amount: -64 -> -4",
+		"mod_matrix[10].amount: -64 -> 6",
+		"oscillators[0].octave: 0 -> 8'",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got changes\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestApplyPatchUpdate(t *testing.T) {
+	sdata := make([]byte, PatchSize)
+	sdata[144] = byte(EffectReverb) // FX2 type
+	sdata[363] = 'X'                // name
+	p, err := ParseSDATA(sdata)
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+
+	merged, err := ApplyPatchUpdate(p, []byte(`{"filters": [null, {"pan": 10}], "effects": [null, {"parameters": {"decay": 90}}]}`), "display")
+	if err != nil {
+		t.Fatalf("failed to merge: %v", err)
+	}
+	if merged.Filters[1].Pan != 74 || merged.Filters[0].Pan != 0 || merged.Effects[1].Params[2] != 90 {
+		t.Errorf("merge gave pan %d/%d, decay %d", merged.Filters[0].Pan, merged.Filters[1].Pan, merged.Effects[1].Params[2])
+	}
+
+	updates, err := changedParameters(p, merged)
+	if err != nil {
+		t.Fatalf("failed to list changes: %v", err)
+	}
+	want := []paramUpdate{{113, 74}, {148, 90}} // filter 2 pan, FX2 decay
+	if !reflect.DeepEqual(updates, want) {
+		t.Errorf("got updates %v, want %v", updates, want)
+	}
+
+	ops, err := ApplyPatchUpdate(p, []byte(`[
+		{"op": "test", "path": "/glide", "value": 0},
+		{"op": "replace", "path": "/oscillators/1/shape", "value": "Saw"},
+		{"op": "add", "path": "/effects/1/parameters/size", "value": 20}
+	]`), "raw")
+	if err != nil {
+		t.Fatalf("failed to apply operations: %v", err)
+	}
+	if ops.Oscillators[1].Shape != OscShape(2) || ops.Effects[1].Params[0] != 20 {
+		t.Errorf("operations gave shape %v, size %d", ops.Oscillators[1].Shape, ops.Effects[1].Params[0])
+	}
+
+	// Changing the type drops the echoed parameters of the old one.
+	delay, err := ApplyPatchUpdate(p, []byte(`{"effects": [null, {"type": "Delay", "parameters": {"feedback": 70}}]}`), "raw")
+	if err != nil {
+		t.Fatalf("failed to change the effect type: %v", err)
+	}
+	if delay.Effects[1].Type != EffectDelay || delay.Effects[1].Params[4] != 70 {
+		t.Errorf("unexpected effect %+v", delay.Effects[1])
+	}
+
+	for _, bad := range []string{
+		`{"filter": [{"cutoff": 1}]}`,
+		`{"filters": [{"cutof": 1}]}`,
+		`[{"op": "test", "path": "/glide", "value": 1}]`,
+		`[{"op": "remove", "path": "/glide"}]`,
+		`[{"op": "replace", "path": "/lfos/3/speed", "value": 1}]`,
+		`[{"op": "replace", "path": "/filters/0", "value": 1}]`,
+		`{"parameters": {"decay": 1}}`,
+		`{"unison": {"parameters": {"decay": 1}}}`,
+		`{"filters": [{"parameters": {"decay": 1}}]}`,
+		`[{"op": "add", "path": "/parameters/decay", "value": 1}]`,
+		`[{"op": "add", "path": "/filters/0/parameters/decay", "value": 1}]`,
+	} {
+		if _, err := ApplyPatchUpdate(p, []byte(bad), "raw"); err == nil {
+			t.Errorf("%s: expected an error", bad)
+		}
+	}
+}
+
+func TestMorphPatches(t *testing.T) {
+	a, err := ParseSDATA(make([]byte, PatchSize))
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	b, _ := ParseSDATA(make([]byte, PatchSize))
+	a.Filters[0].Cutoff, b.Filters[0].Cutoff = 20, 120
+	a.Oscillators[0].Octave, b.Oscillators[0].Octave = 40, 64
+	a.Oscillators[0].Shape, b.Oscillators[0].Shape = 1, 2
+	a.Effects[0].Type, b.Effects[0].Type = EffectChorus, EffectFlanger
+	a.Effects[0].Params[0], b.Effects[0].Params[0] = 10, 100
+	a.Effects[1].Type, b.Effects[1].Type = EffectReverb, EffectReverb
+	a.Effects[1].Params[2], b.Effects[1].Params[2] = 10, 100
+	b.Name = "Other"
+
+	for _, tc := range []struct {
+		t                     float64
+		cutoff, octave, decay byte
+		shape                 OscShape
+		fx1                   EffectType
+		name                  string
+	}{
+		{0, 20, 40, 10, 1, EffectChorus, a.Name},
+		{0.25, 45, 40, 33, 1, EffectChorus, a.Name},
+		{0.5, 70, 64, 55, 2, EffectFlanger, "Other"},
+		{1, 120, 64, 100, 2, EffectFlanger, "Other"},
+	} {
+		p, err := MorphPatches(a, b, tc.t)
+		if err != nil {
+			t.Fatalf("t=%v: %v", tc.t, err)
+		}
+		if p.Filters[0].Cutoff != tc.cutoff || p.Oscillators[0].Octave != tc.octave || p.Effects[1].Params[2] != tc.decay {
+			t.Errorf("t=%v: cutoff %d, octave %d, decay %d", tc.t, p.Filters[0].Cutoff, p.Oscillators[0].Octave, p.Effects[1].Params[2])
+		}
+		if p.Oscillators[0].Shape != tc.shape || p.Effects[0].Type != tc.fx1 || p.Name != tc.name {
+			t.Errorf("t=%v: shape %v, fx1 %v, name %q", tc.t, p.Oscillators[0].Shape, p.Effects[0].Type, p.Name)
+		}
+		// Effects of different types switch with their parameters.
+		if want := map[EffectType]byte{EffectChorus: 10, EffectFlanger: 100}[tc.fx1]; p.Effects[0].Params[0] != want {
+			t.Errorf("t=%v: fx1 param %d, want %d", tc.t, p.Effects[0].Params[0], want)
+		}
+	}
+
+	if _, err := MorphPatches(a, b, 1.5); err == nil {
+		t.Errorf("expected an error for t outside 0..1")
+	}
+}
+
+func TestRandomize(t *testing.T) {
+	data := initSDATA()
+	base, err := ParseSDATA(data)
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+
+	for seed := int64(1); seed <= 20; seed++ {
+		p, _ := ParseSDATA(data)
+		if err := p.Randomize(RandomizeOptions{Amount: 1, Seed: seed}); err != nil {
+			t.Fatalf("seed %d: %v", seed, err)
+		}
+		if err := p.Validate(); err != nil {
+			t.Errorf("seed %d: randomized patch is invalid: %v", seed, err)
+		}
+	}
+
+	opts := RandomizeOptions{
+		Sections: []string{"filters", "effects"},
+		Amount:   0.5,
+		Seed:     42,
+		Locks:    []string{"filters[1]", "filters[].cutoff"},
+	}
+	a, _ := ParseSDATA(data)
+	b, _ := ParseSDATA(data)
+	if err := a.Randomize(opts); err != nil {
+		t.Fatalf("failed to randomize: %v", err)
+	}
+	_ = b.Randomize(opts)
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("the same seed gave different patches")
+	}
+
+	if a.Filters[1] != base.Filters[1] || a.Filters[0].Cutoff != base.Filters[0].Cutoff {
+		t.Errorf("locked fields changed: %+v, cutoff %d", a.Filters[1], a.Filters[0].Cutoff)
+	}
+	if a.Oscillators != base.Oscillators || a.Envelopes != base.Envelopes {
+		t.Errorf("sections outside filters and effects changed")
+	}
+	if reflect.DeepEqual(a.Filters[0], base.Filters[0]) {
+		t.Errorf("filter 1 did not change")
+	}
+
+	a.RandomizeOscillators(7)
+	b.RandomizeOscillators(7)
+	if a.Oscillators != b.Oscillators {
+		t.Errorf("the same seed gave different oscillators")
+	}
+
+	if err := a.Randomize(RandomizeOptions{Sections: []string{"wavetables"}, Amount: 1}); err == nil {
+		t.Errorf("expected an error for an unknown section")
+	}
+}
+
+func TestSeedFlag(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		want int64
+	}{
+		{[]string{"-seed", "0"}, 0},
+		{[]string{"-seed", "42"}, 42},
+	} {
+		fs := flag.NewFlagSet("randomize", flag.ContinueOnError)
+		seed := fs.Int64("seed", 0, "")
+		if err := fs.Parse(tc.args); err != nil {
+			t.Fatalf("failed to parse %v: %v", tc.args, err)
+		}
+		if got := seedFlag(fs, *seed); got != tc.want {
+			t.Errorf("%v gave seed %d, want %d", tc.args, got, tc.want)
+		}
+	}
+}
+
+func TestBreed(t *testing.T) {
+	a, err := ParseSDATA(make([]byte, PatchSize))
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	b := copyPatch(a)
+	for i := range a.Filters {
+		a.Filters[i].Cutoff, b.Filters[i].Cutoff = 10, 100
+	}
+	for i := range a.Envelopes {
+		a.Envelopes[i].Attack, b.Envelopes[i].Attack = 10, 100
+	}
+
+	children, err := Breed([]*Patch{a, b}, BreedOptions{Children: 20, Seed: 3})
+	if err != nil {
+		t.Fatalf("failed to breed: %v", err)
+	}
+	mixed := false
+	for i, c := range children {
+		// Without mutation every section comes whole from one parent.
+		if c.Filters[0].Cutoff != c.Filters[1].Cutoff || c.Envelopes[0].Attack != c.Envelopes[3].Attack {
+			t.Errorf("child %d mixes a section: %+v %+v", i, c.Filters, c.Envelopes)
+		}
+		if c.Filters[0].Cutoff != c.Envelopes[0].Attack {
+			mixed = true
+		}
+	}
+	if !mixed {
+		t.Errorf("no child combines sections of both parents")
+	}
+
+	again, _ := Breed([]*Patch{a, b}, BreedOptions{Children: 20, Seed: 3})
+	if !reflect.DeepEqual(children, again) {
+		t.Errorf("the same seed gave different children")
+	}
+
+	pop, err := NewPopulation([]*Patch{a, b}, BreedOptions{Children: 4, Mutation: 0.2, Seed: 5})
+	if err != nil {
+		t.Fatalf("failed to start population: %v", err)
+	}
+	if err := pop.Next(6); err == nil {
+		t.Errorf("expected an error without kept children")
+	}
+	_ = pop.Judge(2, VerdictKeep)
+	_ = pop.Judge(3, VerdictDiscard)
+	if err := pop.Judge(5, VerdictKeep); err == nil {
+		t.Errorf("expected an error for child 5 of 4")
+	}
+	kept := pop.Children[1]
+	if err := pop.Next(6); err != nil {
+		t.Fatalf("failed to breed the next generation: %v", err)
+	}
+	if pop.Generation != 2 || len(pop.Parents) != 1 || pop.Parents[0] != kept || pop.Verdicts[1] != VerdictUndecided {
+		t.Errorf("unexpected second generation: %s", pop)
+	}
+}
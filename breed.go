@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// BreedOptions controls Breed.
+type BreedOptions struct {
+	Children int     // number of children
+	Mutation float64 // Randomize amount applied to every child, 0 to 1
+	Seed     int64
+}
+
+// Breed produces children of the parents. Each section of randomSections
+// (oscillators, filters, envelopes, modulation, ...) is copied as a whole
+// from a randomly chosen parent, then the child is mutated with Randomize.
+// With a single parent the children are mutations of it. The same parents
+// and options always give the same children.
+func Breed(parents []*Patch, opts BreedOptions) ([]*Patch, error) {
+	if len(parents) == 0 {
+		return nil, fmt.Errorf("breeding needs at least one parent")
+	}
+	if opts.Children < 1 {
+		return nil, fmt.Errorf("breeding needs at least one child, got %d", opts.Children)
+	}
+	if opts.Mutation < 0 || opts.Mutation > 1 || math.IsNaN(opts.Mutation) {
+		return nil, fmt.Errorf("mutation %v is outside 0..1", opts.Mutation)
+	}
+
+	rng := rand.New(rand.NewSource(opts.Seed))
+	children := make([]*Patch, opts.Children)
+	for i := range children {
+		child := copyPatch(parents[rng.Intn(len(parents))])
+		for _, section := range randomSectionNames() {
+			copySection(child, parents[rng.Intn(len(parents))], section)
+		}
+		if err := child.Randomize(RandomizeOptions{Amount: opts.Mutation, Seed: rng.Int63()}); err != nil {
+			return nil, err
+		}
+		children[i] = child
+	}
+	return children, nil
+}
+
+func copyPatch(p *Patch) *Patch {
+	c := *p
+	if p.Raw != nil {
+		c.Raw = append([]byte(nil), p.Raw...)
+	}
+	return &c
+}
+
+// copySection copies the fields of one section of randomSections.
+func copySection(dst, src *Patch, section string) {
+	fields := make(map[string]bool)
+	for _, f := range randomSections[section] {
+		fields[f] = true
+	}
+
+	d, s := reflect.ValueOf(dst).Elem(), reflect.ValueOf(src).Elem()
+	typ := d.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+		if fields[name] {
+			d.Field(i).Set(s.Field(i))
+		}
+	}
+}
+
+// Verdict is the feedback on a child of a Population.
+type Verdict int
+
+const (
+	VerdictUndecided Verdict = iota
+	VerdictKeep
+	VerdictDiscard
+)
+
+var verdictNames = []string{"undecided", "keep", "discard"}
+
+func (v Verdict) String() string { return enumName(byte(v), verdictNames) }
+
+// Population is a breeding session: the children of the current generation
+// and the verdicts on them. The kept children are the parents of the next
+// generation.
+type Population struct {
+	Parents    []*Patch
+	Children   []*Patch
+	Verdicts   []Verdict
+	Generation int
+	Seed       int64 // seed of the current generation
+	Options    BreedOptions
+}
+
+// NewPopulation breeds the first generation from parents.
+func NewPopulation(parents []*Patch, opts BreedOptions) (*Population, error) {
+	pop := &Population{Options: opts}
+	if err := pop.breed(parents, opts.Seed); err != nil {
+		return nil, err
+	}
+	return pop, nil
+}
+
+func (pop *Population) breed(parents []*Patch, seed int64) error {
+	opts := pop.Options
+	opts.Seed = seed
+	children, err := Breed(parents, opts)
+	if err != nil {
+		return err
+	}
+	pop.Parents = parents
+	pop.Children = children
+	pop.Verdicts = make([]Verdict, len(children))
+	pop.Generation++
+	pop.Seed = seed
+	return nil
+}
+
+// Child returns child n, counted from 1.
+func (pop *Population) Child(n int) (*Patch, error) {
+	if n < 1 || n > len(pop.Children) {
+		return nil, fmt.Errorf("child %d does not exist, generation %d has children 1-%d", n, pop.Generation, len(pop.Children))
+	}
+	return pop.Children[n-1], nil
+}
+
+// Judge records the verdict on child n, counted from 1.
+func (pop *Population) Judge(n int, v Verdict) error {
+	if _, err := pop.Child(n); err != nil {
+		return err
+	}
+	pop.Verdicts[n-1] = v
+	return nil
+}
+
+// Next breeds a new generation from the kept children.
+func (pop *Population) Next(seed int64) error {
+	var kept []*Patch
+	for i, v := range pop.Verdicts {
+		if v == VerdictKeep {
+			kept = append(kept, pop.Children[i])
+		}
+	}
+	if len(kept) == 0 {
+		return fmt.Errorf("keep at least one child of generation %d first", pop.Generation)
+	}
+	return pop.breed(kept, seed)
+}
+
+// String summarizes the current generation.
+func (pop *Population) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Generation %d (seed %d) of %d parents:\n", pop.Generation, pop.Seed, len(pop.Parents))
+	for i, c := range pop.Children {
+		fmt.Fprintf(&sb, "%d. %s [%s]\n", i+1, strings.TrimRight(c.Name, " "), pop.Verdicts[i])
+	}
+	return sb.String()
+}
+
+const breedHelp = `commands:
+  <n>          load child n into the edit buffer
+  k <n>        keep child n
+  d <n>        discard child n
+  next         breed the next generation from the kept children
+  w <n> <file> write child n to a JSON file
+  q            quit`
+
+func breedPatches(blo *Blofeld, args []string) {
+	fs := flag.NewFlagSet("breed", flag.ExitOnError)
+	children := fs.Int("children", 6, "children per generation")
+	mutation := fs.Float64("mutation", 0.1, "how much each child is mutated, 0 to 1")
+	seed := fs.Int64("seed", 0, "seed of the first generation (default: a new seed)")
+	instrument := fs.Int("instrument", 1, "instrument whose edit buffer is used for auditioning (1-16)")
+	format := fs.String("format", "raw", "format of JSON patch files: raw or display")
+	_ = fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		log.Fatalf("usage: breed [-children 6] [-mutation 0.1] [-seed N] [-instrument 1] [-format raw|display] <parent>... (each a file, a slot like A001, edit or edit:N)")
+	}
+
+	var parents []*Patch
+	for _, source := range fs.Args() {
+		p, err := loadPatchArg(blo, source, *format)
+		if err != nil {
+			log.Fatalf("failed to load %s: %v", source, err)
+		}
+		parents = append(parents, p)
+	}
+
+	pop, err := NewPopulation(parents, BreedOptions{Children: *children, Mutation: *mutation, Seed: seedFlag(fs, *seed)})
+	if err != nil {
+		log.Fatalf("failed to breed: %v", err)
+	}
+
+	fmt.Println(breedHelp)
+	fmt.Print(pop)
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for fmt.Print("> "); scanner.Scan(); fmt.Print("> ") {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		var err error
+		switch cmd := fields[0]; {
+		case cmd == "q":
+			return
+		case cmd == "next":
+			err = pop.Next(newSeed())
+			if err == nil {
+				fmt.Print(pop)
+			}
+		case (cmd == "k" || cmd == "d") && len(fields) == 2:
+			verdict := VerdictKeep
+			if cmd == "d" {
+				verdict = VerdictDiscard
+			}
+			err = judgeChild(pop, fields[1], verdict)
+		case cmd == "w" && len(fields) == 3:
+			err = writeChild(pop, fields[1], fields[2], *format)
+		default:
+			var n int
+			if n, err = strconv.Atoi(cmd); err != nil {
+				fmt.Println(breedHelp)
+				err = nil
+				continue
+			}
+			var child *Patch
+			if child, err = pop.Child(n); err == nil {
+				if err = child.Validate(); err == nil {
+					err = blo.SendInstrument(*instrument, child)
+				}
+			}
+		}
+		if err != nil {
+			fmt.Println("error:", err)
+		}
+	}
+}
+
+func judgeChild(pop *Population, arg string, v Verdict) error {
+	n, err := strconv.Atoi(arg)
+	if err != nil {
+		return fmt.Errorf("invalid child %q", arg)
+	}
+	return pop.Judge(n, v)
+}
+
+func writeChild(pop *Population, arg string, path string, format string) error {
+	n, err := strconv.Atoi(arg)
+	if err != nil {
+		return fmt.Errorf("invalid child %q", arg)
+	}
+	child, err := pop.Child(n)
+	if err != nil {
+		return err
+	}
+	asJson, err := marshalPatch(child, format)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, asJson, 0o644)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Config holds the connection settings for one Blofeld.
+type Config struct {
+	Port     string `json:"port"`      // case-insensitive fragment of the MIDI port name
+	DeviceID byte   `json:"device_id"` // SysEx device ID, 0x7F = broadcast
+	Channel  int    `json:"channel"`   // MIDI channel 1–16, as shown on the Blofeld
+	Discover bool   `json:"discover"`  // read device ID and channel from the global settings
+	Debug    bool   `json:"debug"`     // hex dump every sound sent and received to stderr
+}
+
+// configFile is the on-disk format: top-level defaults plus named profiles,
+// e.g. one per Blofeld in a studio with several units. Profiles only need to
+// list the settings that differ from the defaults.
+type configFile struct {
+	Config
+	Profiles map[string]json.RawMessage `json:"profiles"`
+}
+
+func defaultConfig() Config {
+	return Config{
+		Port:     "blofeld",
+		DeviceID: 0x00,
+		Channel:  5, // factory setting
+	}
+}
+
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "blofeldmcp", "config.json")
+}
+
+// loadConfig applies the config file (if any), the selected profile and then
+// the command line flags on top of the defaults. It returns the remaining
+// arguments, starting with the subcommand.
+func loadConfig(args []string) (Config, []string, error) {
+	flags := flag.NewFlagSet("blofeldmcp", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: blofeldmcp [flags] <command> [command flags]\n\n")
+		fmt.Fprintf(flags.Output(), "commands: mcp, get, set, single, play, globals, backup, restore, diff, morph, randomize, breed, emulate\n\nflags:\n")
+		flags.PrintDefaults()
+	}
+
+	configPath := flags.String("config", "", "config file (default "+defaultConfigPath()+")")
+	profile := flags.String("profile", "", "named profile from the config file")
+	port := flags.String("port", "", "fragment of the MIDI port name")
+	deviceID := flags.Int("device-id", 0, "SysEx device ID (0-127, 127 = broadcast)")
+	channel := flags.Int("channel", 0, "MIDI channel (1-16)")
+	discover := flags.Bool("discover", false, "read device ID and MIDI channel from the Blofeld's global settings")
+	debug := flags.Bool("debug", false, "hex dump every sound sent and received to stderr")
+	if err := flags.Parse(args); err != nil {
+		return Config{}, nil, err
+	}
+
+	cfg := defaultConfig()
+
+	path := *configPath
+	if path == "" {
+		path = defaultConfigPath()
+	}
+	if path != "" {
+		if err := readConfigFile(path, *profile, &cfg); err != nil {
+			// The default location is optional; an explicitly given file is not.
+			if *configPath != "" || !errors.Is(err, fs.ErrNotExist) {
+				return Config{}, nil, err
+			}
+			if *profile != "" {
+				return Config{}, nil, fmt.Errorf("profile %q requested but no config file found at %s", *profile, path)
+			}
+		}
+	}
+
+	var flagErr error
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "port":
+			cfg.Port = *port
+		case "device-id":
+			if *deviceID < 0 || *deviceID > 127 {
+				flagErr = fmt.Errorf("device ID must be in range 0–127, got %d", *deviceID)
+			}
+			cfg.DeviceID = byte(*deviceID)
+		case "channel":
+			cfg.Channel = *channel
+		case "discover":
+			cfg.Discover = *discover
+		case "debug":
+			cfg.Debug = *debug
+		}
+	})
+	if flagErr != nil {
+		return Config{}, nil, flagErr
+	}
+
+	// Checked here too because the config file may set it as well.
+	if cfg.DeviceID > 0x7F {
+		return Config{}, nil, fmt.Errorf("device ID must be in range 0–127, got %d", cfg.DeviceID)
+	}
+	if cfg.Channel < 1 || cfg.Channel > 16 {
+		return Config{}, nil, fmt.Errorf("channel must be in range 1–16, got %d", cfg.Channel)
+	}
+
+	return cfg, flags.Args(), nil
+}
+
+func readConfigFile(path string, profile string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	file := configFile{Config: *cfg}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	*cfg = file.Config
+
+	if profile == "" {
+		return nil
+	}
+
+	raw, ok := file.Profiles[profile]
+	if !ok {
+		return fmt.Errorf("profile %q not found in %s", profile, path)
+	}
+	if err := json.Unmarshal(raw, cfg); err != nil {
+		return fmt.Errorf("failed to parse profile %q in %s: %w", profile, path, err)
+	}
+	return nil
+}
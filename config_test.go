@@ -0,0 +1,46 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	config := `{
+  "port": "blofeld",
+  "channel": 3,
+  "profiles": {
+    "second": {"port": "Blofeld 2", "device_id": 1}
+  }
+}`
+	if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	cfg, args, err := loadConfig([]string{"-config", path, "-profile", "second", "-channel", "7", "get", "-bank", "A"})
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	want := Config{Port: "Blofeld 2", DeviceID: 1, Channel: 7}
+	if cfg != want {
+		t.Errorf("expected %+v, got %+v", want, cfg)
+	}
+	if len(args) != 3 || args[0] != "get" {
+		t.Errorf("unexpected remaining args %v", args)
+	}
+
+	if _, _, err := loadConfig([]string{"-config", path, "-profile", "missing"}); err == nil {
+		t.Errorf("expected error for unknown profile")
+	}
+
+	bad := filepath.Join(t.TempDir(), "bad.json")
+	if err := os.WriteFile(bad, []byte(`{"device_id": 200}`), 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	if _, _, err := loadConfig([]string{"-config", bad}); err == nil {
+		t.Errorf("expected error for device ID 200")
+	}
+}
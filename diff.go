@@ -0,0 +1,259 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// FieldChange is one difference between two patches, in display units.
+type FieldChange struct {
+	Path string `json:"path"` // JSON path, e.g. "filters[0].cutoff"
+	Old  any    `json:"old"`
+	New  any    `json:"new"`
+}
+
+// DiffPatches lists the fields that differ between a and b, in patch order.
+// Values are compared in their display units; the raw SDATA is not compared
+// byte by byte, so reserved bytes do not show up.
+func DiffPatches(a, b *Patch) ([]FieldChange, error) {
+	fa, err := flattenPatch(a)
+	if err != nil {
+		return nil, err
+	}
+	fb, err := flattenPatch(b)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make(map[string]bool)
+	for path := range fa {
+		paths[path] = true
+	}
+	for path := range fb {
+		paths[path] = true
+	}
+
+	var changes []FieldChange
+	for path := range paths {
+		oldVal, newVal := fa[path], fb[path]
+		if fmt.Sprint(oldVal) != fmt.Sprint(newVal) {
+			changes = append(changes, FieldChange{Path: path, Old: oldVal, New: newVal})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return lessPath(changes[i].Path, changes[j].Path) })
+	return changes, nil
+}
+
+// flattenPatch maps every leaf of the display JSON to its path.
+func flattenPatch(p *Patch) (map[string]any, error) {
+	data, err := p.MarshalDisplay()
+	if err != nil {
+		return nil, err
+	}
+	tree, err := decodeTree(data)
+	if err != nil {
+		return nil, err
+	}
+
+	leaves := make(map[string]any)
+	var walk func(v any, path string)
+	walk = func(v any, path string) {
+		switch t := v.(type) {
+		case map[string]any:
+			for k, child := range t {
+				if path == "" && k == "raw" {
+					continue
+				}
+				if path != "" {
+					k = path + "." + k
+				}
+				walk(child, k)
+			}
+		case []any:
+			for i, child := range t {
+				walk(child, fmt.Sprintf("%s[%d]", path, i))
+			}
+		default:
+			leaves[path] = v
+		}
+	}
+	walk(tree, "")
+	return leaves, nil
+}
+
+// lessPath orders paths naturally, so "mod_matrix[2]" sorts before
+// "mod_matrix[10]".
+func lessPath(a, b string) bool {
+	for a != "" && b != "" {
+		if unicode.IsDigit(rune(a[0])) && unicode.IsDigit(rune(b[0])) {
+			na, restA := leadingNumber(a)
+			nb, restB := leadingNumber(b)
+			if na != nb {
+				return na < nb
+			}
+			a, b = restA, restB
+			continue
+		}
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) < len(b)
+}
+
+func leadingNumber(s string) (int, string) {
+	i := 0
+	for i < len(s) && unicode.IsDigit(rune(s[i])) {
+		i++
+	}
+	n, _ := strconv.Atoi(s[:i])
+	return n, s[i:]
+}
+
+// patchLocation is a stored slot or the edit buffer of an instrument, named
+// like "A001", "edit" or "edit:3".
+type patchLocation struct {
+	Bank       string // empty for an edit buffer
+	Program    int
+	Instrument int
+}
+
+func parsePatchLocation(s string) (patchLocation, error) {
+	s = strings.TrimSpace(s)
+	if s == "edit" || strings.HasPrefix(s, "edit:") {
+		l := patchLocation{Instrument: 1}
+		if _, n, found := strings.Cut(s, ":"); found {
+			var err error
+			if l.Instrument, err = strconv.Atoi(n); err != nil {
+				return patchLocation{}, fmt.Errorf("edit buffer must look like edit:3, got %q", s)
+			}
+		}
+		if _, err := instrumentToByte(l.Instrument); err != nil {
+			return patchLocation{}, err
+		}
+		return l, nil
+	}
+
+	bank, program, err := parseSlot(s)
+	if err != nil {
+		return patchLocation{}, fmt.Errorf("location must be a slot (A001), edit or edit:N: %w", err)
+	}
+	return patchLocation{Bank: bank, Program: program}, nil
+}
+
+func (l patchLocation) String() string {
+	if l.Bank == "" {
+		return fmt.Sprintf("edit buffer of instrument %d", l.Instrument)
+	}
+	return fmt.Sprintf("%s%03d", l.Bank, l.Program)
+}
+
+func (b *Blofeld) requestLocation(l patchLocation) (*Patch, error) {
+	var p *Patch
+	var err error
+	if l.Bank == "" {
+		p, _, err = b.RequestInstrument(l.Instrument)
+	} else {
+		p, _, err = b.RequestPatchDump(l.Bank, l.Program)
+	}
+	return p, err
+}
+
+// loadPatch resolves a patch source: a location (see parsePatchLocation) or
+// patch JSON in the given format.
+func (b *Blofeld) loadPatch(source string, format string) (*Patch, error) {
+	source = strings.TrimSpace(source)
+
+	if strings.HasPrefix(source, "{") {
+		p := &Patch{}
+		if err := unmarshalPatch([]byte(source), format, p); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal patch JSON: %w", err)
+		}
+		return p, nil
+	}
+
+	l, err := parsePatchLocation(source)
+	if err != nil {
+		return nil, err
+	}
+	return b.requestLocation(l)
+}
+
+// readPatchFile reads a patch from a JSON file in the given format or from
+// the first sound of a .syx file.
+func readPatchFile(path string, format string) (*Patch, error) {
+	if strings.EqualFold(filepath.Ext(path), ".syx") {
+		sounds, err := ReadSyxFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return sounds[0].Patch, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p := &Patch{}
+	if err := unmarshalPatch(data, format, p); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return p, nil
+}
+
+// loadPatchArg resolves a command line patch argument: an existing file (see
+// readPatchFile) or a patch source for loadPatch.
+func loadPatchArg(blo *Blofeld, source string, format string) (*Patch, error) {
+	if _, err := os.Stat(source); err == nil {
+		return readPatchFile(source, format)
+	}
+	return blo.loadPatch(source, format)
+}
+
+func diffPatches(blo *Blofeld, args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	format := fs.String("format", "raw", "format of JSON patch files: raw or display")
+	asJSON := fs.Bool("json", false, "print the changes as JSON")
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		log.Fatalf("usage: diff [-format raw|display] [-json] <old> <new> (each a file, a slot like A001, edit or edit:N)")
+	}
+
+	var patches [2]*Patch
+	for i, source := range fs.Args() {
+		var err error
+		if patches[i], err = loadPatchArg(blo, source, *format); err != nil {
+			log.Fatalf("failed to load %s: %v", source, err)
+		}
+	}
+
+	changes, err := DiffPatches(patches[0], patches[1])
+	if err != nil {
+		log.Fatalf("failed to diff patches: %v", err)
+	}
+
+	if *asJSON {
+		asJson, err := json.MarshalIndent(changes, "", "  ")
+		if err != nil {
+			log.Fatalf("failed to marshal diff: %v", err)
+		}
+		fmt.Println(string(asJson))
+		return
+	}
+
+	for _, c := range changes {
+		fmt.Printf("%s: %v -> %v\n", c.Path, c.Old, c.New)
+	}
+	log.Printf("%d fields differ\n", len(changes))
+}
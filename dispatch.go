@@ -0,0 +1,151 @@
+package main
+
+import (
+	"sync"
+)
+
+// dispatcher is the long-lived listener on the Blofeld's input. Every
+// received SysEx message goes to the oldest pending request it answers;
+// messages nobody asked for, such as parameter changes sent while the user
+// turns a knob, go to the subscribers instead.
+type dispatcher struct {
+	transport Transport
+
+	mu          sync.Mutex
+	stop        func()
+	pending     []*pendingReply
+	subscribers map[int]func([]byte)
+	nextID      int
+}
+
+// pendingReply is a request waiting for count more replies.
+type pendingReply struct {
+	match   func(msg []byte) bool
+	count   int
+	replies chan []byte
+}
+
+func newDispatcher(t Transport) *dispatcher {
+	return &dispatcher{transport: t, subscribers: make(map[int]func([]byte))}
+}
+
+// replyTo matches the Blofeld messages from devID with the given IDM and
+// location bytes, e.g. replyTo(devID, 0x10, bank, program) for the SNDD
+// answering an SNDR. A request to the broadcast ID accepts any device ID.
+func replyTo(devID byte, idm byte, location ...byte) func(msg []byte) bool {
+	return func(msg []byte) bool {
+		if len(msg) < 6+len(location) || msg[1] != 0x3E || msg[2] != 0x13 || msg[4] != idm {
+			return false
+		}
+		if devID != broadcastDeviceID && msg[3] != devID {
+			return false
+		}
+		for i, l := range location {
+			if msg[5+i] != l {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// listen starts listening on the transport unless already done. The caller
+// must hold d.mu.
+func (d *dispatcher) listen() error {
+	if d.stop != nil {
+		return nil
+	}
+	stop, err := d.transport.ListenSysEx(d.dispatch)
+	if err != nil {
+		return err
+	}
+	d.stop = stop
+	return nil
+}
+
+// expect registers a request for count replies matching match. Register
+// before sending the request so a fast reply is not missed. The replies
+// arrive on the returned channel; cancel must be called when done waiting.
+func (d *dispatcher) expect(match func(msg []byte) bool, count int) (<-chan []byte, func(), error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.listen(); err != nil {
+		return nil, nil, err
+	}
+
+	p := &pendingReply{match: match, count: count, replies: make(chan []byte, count)}
+	d.pending = append(d.pending, p)
+
+	return p.replies, func() {
+		d.mu.Lock()
+		d.remove(p)
+		d.mu.Unlock()
+	}, nil
+}
+
+// subscribe calls fn with every message that answers no pending request,
+// until unsubscribe is called.
+func (d *dispatcher) subscribe(fn func(msg []byte)) (func(), error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.listen(); err != nil {
+		return nil, err
+	}
+
+	id := d.nextID
+	d.nextID++
+	d.subscribers[id] = fn
+
+	return func() {
+		d.mu.Lock()
+		delete(d.subscribers, id)
+		d.mu.Unlock()
+	}, nil
+}
+
+func (d *dispatcher) remove(p *pendingReply) {
+	for i, q := range d.pending {
+		if q == p {
+			d.pending = append(d.pending[:i], d.pending[i+1:]...)
+			return
+		}
+	}
+}
+
+func (d *dispatcher) dispatch(msg []byte) {
+	d.mu.Lock()
+	for _, p := range d.pending {
+		if p.match(msg) {
+			p.replies <- append([]byte(nil), msg...)
+			if p.count--; p.count == 0 {
+				d.remove(p)
+			}
+			d.mu.Unlock()
+			return
+		}
+	}
+
+	subscribers := make([]func([]byte), 0, len(d.subscribers))
+	for _, fn := range d.subscribers {
+		subscribers = append(subscribers, fn)
+	}
+	d.mu.Unlock()
+
+	for _, fn := range subscribers {
+		fn(msg)
+	}
+}
+
+// close stops listening. Pending requests time out.
+func (d *dispatcher) close() {
+	d.mu.Lock()
+	stop := d.stop
+	d.stop = nil
+	d.mu.Unlock()
+
+	if stop != nil {
+		stop()
+	}
+}
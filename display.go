@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Unit selects how a raw SDATA value appears in the display view of a patch.
+type Unit int
+
+const (
+	UnitRaw       Unit = iota
+	UnitSigned         // 0..127 shown as -64..+63
+	UnitSemitones      // offset 64: semitone 52..76 is -12..+12, bend range 40..88 is -24..+24
+	UnitPercent        // keytrack 0..127 shown as -200%..+196% in steps of 3.125
+	UnitFeet           // octave 16..112 shown as 128'..1/2'
+)
+
+// displayUnits lists the offset-encoded fields of the Patch JSON. Array
+// elements are addressed with "[]", e.g. "filters[].pan". Fields not listed
+// here, including the named enums, are the same in both views.
+var displayUnits = map[string]Unit{
+	"oscillators[].octave":     UnitFeet,
+	"oscillators[].pitch":      UnitSemitones,
+	"oscillators[].bend_range": UnitSemitones,
+	"oscillators[].detune":     UnitSigned,
+	"oscillators[].keytrack":   UnitPercent,
+	"oscillators[].pwm":        UnitSigned,
+	"osc_pitch_amount":         UnitSigned,
+
+	"mix_osc1_balance":  UnitSigned,
+	"mix_osc2_balance":  UnitSigned,
+	"mix_osc3_balance":  UnitSigned,
+	"mix_noise_balance": UnitSigned,
+	"mix_noise_color":   UnitSigned,
+	"mix_ring_balance":  UnitSigned,
+
+	"filters[].keytrack":   UnitPercent,
+	"filters[].env_amt":    UnitSigned,
+	"filters[].env_vel":    UnitSigned,
+	"filters[].mod_amount": UnitSigned,
+	"filters[].pan":        UnitSigned,
+	"filters[].pan_amount": UnitSigned,
+
+	"amp_velocity":   UnitSigned,
+	"amp_mod_amount": UnitSigned,
+
+	"lfos[].fade":     UnitSigned,
+	"lfos[].keytrack": UnitPercent,
+
+	"mod_matrix[].amount":         UnitSigned,
+	"effects[].parameters.spread": UnitSigned,
+	"modifiers[].constant":        UnitSigned,
+}
+
+var feetNames = []string{"128'", "64'", "32'", "16'", "8'", "4'", "2'", "1'", "1/2'"}
+
+const (
+	feetBase = 16
+	feetStep = 12
+
+	percentStep = 3.125
+)
+
+// toDisplay converts a raw value. Every raw byte has a display value, so the
+// conversion is lossless; octaves between the feet steps stay numeric.
+func toDisplay(u Unit, raw byte) any {
+	switch u {
+	case UnitSigned, UnitSemitones:
+		return int(raw) - 64
+	case UnitPercent:
+		return (float64(raw) - 64) * percentStep
+	case UnitFeet:
+		if raw >= feetBase && (raw-feetBase)%feetStep == 0 && int(raw-feetBase)/feetStep < len(feetNames) {
+			return feetNames[(raw-feetBase)/feetStep]
+		}
+		return int(raw)
+	}
+	return int(raw)
+}
+
+func fromDisplay(u Unit, v any) (byte, error) {
+	if u == UnitFeet {
+		if s, ok := v.(string); ok {
+			s = strings.TrimSpace(s)
+			for i, name := range feetNames {
+				if s == name || s+"'" == name {
+					return byte(feetBase + i*feetStep), nil
+				}
+			}
+			return 0, fmt.Errorf("unknown octave %q (valid: %s)", s, strings.Join(feetNames, ", "))
+		}
+	}
+
+	n, err := displayNumber(v)
+	if err != nil {
+		return 0, err
+	}
+
+	var raw float64
+	switch u {
+	case UnitSigned, UnitSemitones:
+		raw = n + 64
+	case UnitPercent:
+		raw = math.Round(n/percentStep) + 64
+	default:
+		raw = n
+	}
+	if raw != math.Trunc(raw) || raw < 0 || raw > 127 {
+		return 0, fmt.Errorf("value %v is out of range", v)
+	}
+	return byte(raw), nil
+}
+
+func displayNumber(v any) (float64, error) {
+	switch n := v.(type) {
+	case json.Number:
+		return n.Float64()
+	case float64:
+		return n, nil
+	}
+	return 0, fmt.Errorf("expected a number, got %v", v)
+}
+
+// convertUnits walks a decoded JSON tree and replaces the values of the
+// fields listed in displayUnits with the result of conv.
+func convertUnits(v any, path string, conv func(Unit, any) (any, error)) (any, error) {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, child := range t {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			c, err := convertUnits(child, p, conv)
+			if err != nil {
+				return nil, err
+			}
+			t[k] = c
+		}
+		return t, nil
+	case []any:
+		for i, child := range t {
+			c, err := convertUnits(child, path+"[]", conv)
+			if err != nil {
+				return nil, err
+			}
+			t[i] = c
+		}
+		return t, nil
+	}
+
+	u, ok := displayUnits[path]
+	if !ok {
+		return v, nil
+	}
+	c, err := conv(u, v)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+func decodeTree(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var tree any
+	if err := dec.Decode(&tree); err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+// MarshalDisplay returns the patch as JSON with offset-encoded values in their
+// real units: signed amounts, semitones, keytrack percent and octave feet.
+func (p *Patch) MarshalDisplay() ([]byte, error) {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := decodeTree(data)
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err = convertUnits(tree, "", func(u Unit, v any) (any, error) {
+		n, err := displayNumber(v)
+		if err != nil {
+			return nil, err
+		}
+		return toDisplay(u, byte(n)), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(tree, "", "  ")
+}
+
+// UnmarshalDisplay reads JSON produced by MarshalDisplay into p. As with
+// json.Unmarshal, fields missing from data are left unchanged.
+func (p *Patch) UnmarshalDisplay(data []byte) error {
+	tree, err := decodeTree(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse display JSON: %w", err)
+	}
+
+	tree, err = convertUnits(tree, "", func(u Unit, v any) (any, error) {
+		return fromDisplay(u, v)
+	})
+	if err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(tree)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, p)
+}
+
+// marshalPatch encodes p in the given format, "raw" (the default) or
+// "display". "json" is accepted as an alias of "raw".
+func marshalPatch(p *Patch, format string) ([]byte, error) {
+	switch format {
+	case "", "raw", "json":
+		return json.MarshalIndent(p, "", "  ")
+	case "display":
+		return p.MarshalDisplay()
+	}
+	return nil, fmt.Errorf("unknown patch format %q (want raw or display)", format)
+}
+
+func unmarshalPatch(data []byte, format string, p *Patch) error {
+	switch format {
+	case "", "raw", "json":
+		return json.Unmarshal(data, p)
+	case "display":
+		return p.UnmarshalDisplay(data)
+	}
+	return fmt.Errorf("unknown patch format %q (want raw or display)", format)
+}
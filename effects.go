@@ -0,0 +1,322 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// EffectType is the type of an effect unit (spec 5.1). Delay, Clk.Delay and
+// Reverb are only available on FX2.
+type EffectType byte
+
+const (
+	EffectBypass EffectType = iota
+	EffectChorus
+	EffectFlanger
+	EffectPhaser
+	EffectOverdrive
+	EffectTripleFX
+	EffectDelay
+	EffectClkDelay
+	EffectReverb
+)
+
+var effectTypeNames = []string{
+	"Bypass", "Chorus", "Flanger", "Phaser", "Overdrive", "Triple FX", "Delay",
+	"Clk.Delay", "Reverb",
+}
+
+func (v EffectType) String() string { return enumName(byte(v), effectTypeNames) }
+
+func (v EffectType) MarshalJSON() ([]byte, error) { return marshalEnum(byte(v), effectTypeNames) }
+
+func (v *EffectType) UnmarshalJSON(data []byte) error {
+	b, err := unmarshalEnum(data, effectTypeNames, "effect type")
+	*v = EffectType(b)
+	return err
+}
+
+// FX2Only reports whether the type is only available on the second unit.
+func (v EffectType) FX2Only() bool {
+	return v >= EffectDelay
+}
+
+// EffectParam is a named parameter of one effect type (spec 5.2–5.9).
+type EffectParam struct {
+	Name   string   `json:"name"`
+	Slot   int      `json:"slot"` // offset from Parameter 1 (index 130 for FX1, 146 for FX2)
+	Max    byte     `json:"max"`
+	Signed bool     `json:"signed,omitempty"` // -64..+63, centred on 64
+	Values []string `json:"values,omitempty"` // names of enumerated values
+}
+
+var polarityNames = []string{"positive", "negative"}
+
+var effectParams = map[EffectType][]EffectParam{
+	EffectChorus: {
+		{Name: "speed", Slot: 0, Max: 127},
+		{Name: "depth", Slot: 1, Max: 127},
+	},
+	EffectFlanger: {
+		{Name: "speed", Slot: 0, Max: 127},
+		{Name: "depth", Slot: 1, Max: 127},
+		{Name: "feedback", Slot: 4, Max: 127},
+		{Name: "polarity", Slot: 8, Max: 1, Values: polarityNames},
+	},
+	EffectPhaser: {
+		{Name: "speed", Slot: 0, Max: 127},
+		{Name: "depth", Slot: 1, Max: 127},
+		{Name: "feedback", Slot: 4, Max: 127},
+		{Name: "center", Slot: 5, Max: 127},
+		{Name: "spacing", Slot: 6, Max: 127},
+		{Name: "polarity", Slot: 8, Max: 1, Values: polarityNames},
+	},
+	EffectOverdrive: {
+		{Name: "drive", Slot: 1, Max: 127},
+		{Name: "post_gain", Slot: 2, Max: 127},
+		{Name: "cutoff", Slot: 5, Max: 127},
+		{Name: "curve", Slot: 9, Max: 11, Values: driveCurveNames[:12]},
+	},
+	EffectTripleFX: {
+		{Name: "speed", Slot: 0, Max: 127},
+		{Name: "depth", Slot: 1, Max: 127},
+		{Name: "chorus_mix", Slot: 3, Max: 127},
+		{Name: "sample_hold", Slot: 4, Max: 127},
+		{Name: "overdrive", Slot: 5, Max: 127},
+	},
+	EffectDelay: {
+		{Name: "length", Slot: 3, Max: 127},
+		{Name: "feedback", Slot: 4, Max: 127},
+		{Name: "cutoff", Slot: 5, Max: 127},
+		{Name: "polarity", Slot: 8, Max: 1, Values: polarityNames},
+		{Name: "spread", Slot: 9, Max: 127, Signed: true},
+	},
+	EffectClkDelay: {
+		{Name: "feedback", Slot: 4, Max: 127},
+		{Name: "cutoff", Slot: 5, Max: 127},
+		{Name: "polarity", Slot: 8, Max: 1, Values: polarityNames},
+		{Name: "spread", Slot: 9, Max: 127, Signed: true},
+		{Name: "length", Slot: 10, Max: 29}, // 1/96..10 bars
+	},
+	EffectReverb: {
+		{Name: "size", Slot: 0, Max: 127},
+		{Name: "shape", Slot: 1, Max: 127},
+		{Name: "decay", Slot: 2, Max: 127},
+		{Name: "lowpass", Slot: 5, Max: 127},
+		{Name: "highpass", Slot: 6, Max: 127},
+		{Name: "diffusion", Slot: 7, Max: 127},
+		{Name: "damping", Slot: 8, Max: 127},
+	},
+}
+
+func lookupEffectParam(typ EffectType, name string) (EffectParam, error) {
+	var valid []string
+	for _, prm := range effectParams[typ] {
+		if prm.Name == name {
+			return prm, nil
+		}
+		valid = append(valid, prm.Name)
+	}
+	if len(valid) == 0 {
+		return EffectParam{}, fmt.Errorf("effect type %s has no parameters", typ)
+	}
+	return EffectParam{}, fmt.Errorf("effect type %s has no parameter %q (valid: %s)", typ, name, strings.Join(valid, ", "))
+}
+
+func (prm EffectParam) parse(data json.RawMessage) (byte, error) {
+	var v byte
+	if prm.Values != nil {
+		b, err := unmarshalEnum(data, prm.Values, prm.Name)
+		if err != nil {
+			return 0, err
+		}
+		v = b
+	} else {
+		var n int
+		if err := json.Unmarshal(data, &n); err != nil {
+			return 0, fmt.Errorf("%s must be a number, got %s", prm.Name, data)
+		}
+		if n < 0 || n > 127 {
+			return 0, fmt.Errorf("%s must be in range 0–%d, got %d", prm.Name, prm.Max, n)
+		}
+		v = byte(n)
+	}
+	if v > prm.Max {
+		return 0, fmt.Errorf("%s must be in range 0–%d, got %d", prm.Name, prm.Max, v)
+	}
+	return v, nil
+}
+
+// effectJSON is the wire format of Effect. The parameters are given by name
+// for the current type, e.g. {"decay": 90} for a reverb. The raw slots
+// (params, param1, param2) are only read, for JSON written before named
+// parameters existed; given together with named parameters they must agree.
+type effectJSON struct {
+	Type       EffectType                 `json:"type"`
+	Mix        byte                       `json:"mix"`
+	Param1     *byte                      `json:"param1,omitempty"`
+	Param2     *byte                      `json:"param2,omitempty"`
+	Params     *[14]byte                  `json:"params,omitempty"`
+	Parameters map[string]json.RawMessage `json:"parameters,omitempty"`
+}
+
+func (e Effect) MarshalJSON() ([]byte, error) {
+	out := effectJSON{Type: e.Type, Mix: e.Mix}
+
+	for _, prm := range effectParams[e.Type] {
+		var v []byte
+		var err error
+		if prm.Values != nil {
+			v, err = marshalEnum(e.Params[prm.Slot], prm.Values)
+		} else {
+			v, err = json.Marshal(e.Params[prm.Slot])
+		}
+		if err != nil {
+			return nil, err
+		}
+		if out.Parameters == nil {
+			out.Parameters = make(map[string]json.RawMessage)
+		}
+		out.Parameters[prm.Name] = v
+	}
+
+	return json.Marshal(out)
+}
+
+func (e *Effect) UnmarshalJSON(data []byte) error {
+	in := effectJSON{Type: e.Type, Mix: e.Mix}
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+
+	e.Type, e.Mix = in.Type, in.Mix
+	if in.Params != nil {
+		e.Params = *in.Params
+	}
+	if in.Param1 != nil {
+		e.Params[0] = *in.Param1
+	}
+	if in.Param2 != nil {
+		e.Params[1] = *in.Param2
+	}
+	given := func(slot int) bool {
+		return in.Params != nil || (slot == 0 && in.Param1 != nil) || (slot == 1 && in.Param2 != nil)
+	}
+	raw := e.Params
+
+	names := make([]string, 0, len(in.Parameters))
+	for name := range in.Parameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := e.SetNamed(name, in.Parameters[name]); err != nil {
+			return err
+		}
+		prm, _ := lookupEffectParam(e.Type, name)
+		if given(prm.Slot) && raw[prm.Slot] != e.Params[prm.Slot] {
+			return fmt.Errorf("params[%d] is %d but parameters.%s is %d, give only parameters", prm.Slot, raw[prm.Slot], name, e.Params[prm.Slot])
+		}
+	}
+	return nil
+}
+
+// usesSlot reports whether the parameter slot has a meaning for the type.
+func (v EffectType) usesSlot(slot int) bool {
+	for _, prm := range effectParams[v] {
+		if prm.Slot == slot {
+			return true
+		}
+	}
+	return false
+}
+
+// SetNamed sets a named parameter of the effect's current type. The value is
+// a JSON number or, for enumerated parameters, a name.
+func (e *Effect) SetNamed(name string, value json.RawMessage) error {
+	prm, err := lookupEffectParam(e.Type, name)
+	if err != nil {
+		return err
+	}
+	v, err := prm.parse(value)
+	if err != nil {
+		return err
+	}
+
+	e.Params[prm.Slot] = v
+	return nil
+}
+
+// paramUpdate is a single SDATA change, as sent with SNDP.
+type paramUpdate struct {
+	Index int
+	Value byte
+}
+
+// effectUpdates returns the SNDP changes that switch effect unit fx (0 = FX1,
+// 1 = FX2) of the current sound to typ and apply the named parameters, in a
+// stable order. Parameters not given keep their slot bytes from current,
+// which may belong to the previous type; a value above the new type's
+// maximum is rejected.
+func effectUpdates(current *Patch, fx int, typ EffectType, params map[string]json.RawMessage) ([]paramUpdate, error) {
+	if fx < 0 || fx >= len(effectFieldMapping) {
+		return nil, fmt.Errorf("effect must be 1 or 2, got %d", fx+1)
+	}
+	if int(typ) >= len(effectTypeNames) {
+		return nil, fmt.Errorf("unknown effect type %d", typ)
+	}
+	if fx == 0 && typ.FX2Only() {
+		return nil, fmt.Errorf("effect type %s is only available on FX2", typ)
+	}
+
+	m := effectFieldMapping[fx]
+	updates := []paramUpdate{{m.typ, byte(typ)}}
+
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		prm, err := lookupEffectParam(typ, name)
+		if err != nil {
+			return nil, err
+		}
+		v, err := prm.parse(params[name])
+		if err != nil {
+			return nil, err
+		}
+		updates = append(updates, paramUpdate{m.paramsStart + prm.Slot, v})
+	}
+
+	for _, prm := range effectParams[typ] {
+		if _, ok := params[prm.Name]; ok {
+			continue
+		}
+		if v := current.Effects[fx].Params[prm.Slot]; v > prm.Max {
+			return nil, fmt.Errorf("%s would keep %d from the current effect, above its maximum %d for %s; give %s in parameters", prm.Name, v, prm.Max, typ, prm.Name)
+		}
+	}
+	return updates, nil
+}
+
+// effectSchema describes one effect type for clients.
+type effectSchema struct {
+	Type       string        `json:"type"`
+	Value      int           `json:"value"`
+	FX2Only    bool          `json:"fx2_only,omitempty"`
+	Parameters []EffectParam `json:"parameters,omitempty"`
+}
+
+func describeEffects() []effectSchema {
+	schemas := make([]effectSchema, len(effectTypeNames))
+	for i, name := range effectTypeNames {
+		typ := EffectType(i)
+		schemas[i] = effectSchema{Type: name, Value: i, FX2Only: typ.FX2Only(), Parameters: effectParams[typ]}
+	}
+	return schemas
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+
+	"gitlab.com/gomidi/midi/v2/drivers"
+)
+
+const multiInstruments = 16
+
+// Emulator is a software Blofeld. It holds the sound memory (banks A–H), the
+// Sound Mode Edit Buffer, the 16 Multi Mode instrument edit buffers and the
+// global settings, and answers SysEx messages like the device does: SNDR and
+// GLBR are answered with SNDD and GLBD, incoming SNDD, SNDP and GLBD are
+// applied. Messages for another device ID are ignored.
+type Emulator struct {
+	mu           sync.Mutex
+	sounds       [soundBanks][128][]byte
+	editBuffer   []byte
+	multiBuffers [multiInstruments][]byte
+	globals      GlobalSettings
+}
+
+// NewEmulator returns an emulator with every sound and edit buffer set to
+// the init sound.
+func NewEmulator(deviceID byte) *Emulator {
+	e := &Emulator{globals: GlobalSettings{
+		MIDIChannel: 5, // factory setting
+		DeviceID:    deviceID,
+		PopupTime:   20,
+		MasterTune:  64,
+		Transpose:   64,
+		Volume:      127,
+	}}
+
+	sdata := initSDATA()
+	for bank := range e.sounds {
+		for prog := range e.sounds[bank] {
+			e.sounds[bank][prog] = append([]byte(nil), sdata...)
+		}
+	}
+	e.editBuffer = append([]byte(nil), sdata...)
+	for i := range e.multiBuffers {
+		e.multiBuffers[i] = append([]byte(nil), sdata...)
+	}
+	return e
+}
+
+// initSDATA returns the SDATA of a neutral sound named "Init".
+func initSDATA() []byte {
+	data := make([]byte, PatchSize)
+	for _, m := range oscFieldMapping {
+		data[m.octave] = 64
+		data[m.pitch] = 64
+		data[m.bendRange] = 64
+	}
+	copy(data[nameIdx:], NormalizeName("Init"))
+	return data
+}
+
+// Transport returns an in-memory Transport connected to the emulator, so a
+// Blofeld client can run against it in-process.
+func (e *Emulator) Transport() *MemoryTransport {
+	return &MemoryTransport{Reply: e.Handle}
+}
+
+// Handle processes one received MIDI message and returns the replies.
+func (e *Emulator) Handle(msg []byte) [][]byte {
+	if len(msg) < 6 || msg[0] != 0xF0 || msg[len(msg)-1] != 0xF7 || msg[1] != 0x3E || msg[2] != 0x13 {
+		return nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if msg[3] != e.globals.DeviceID && msg[3] != broadcastDeviceID {
+		return nil
+	}
+
+	switch msg[4] {
+	case 0x00: // SNDR
+		if len(msg) == 8 {
+			return e.soundDumps(msg[5], msg[6])
+		}
+	case 0x10: // SNDD
+		if err := e.storeSound(msg); err != nil {
+			log.Printf("[emulator]Ignoring sound dump: %v", err)
+		}
+	case 0x20: // SNDP
+		if err := e.setParameter(msg); err != nil {
+			log.Printf("[emulator]Ignoring parameter change: %v", err)
+		}
+	case 0x04: // GLBR
+		return [][]byte{e.globals.ToGLBD(e.globals.DeviceID)}
+	case 0x14: // GLBD
+		g, _, err := parseGLBD(msg)
+		if err != nil {
+			log.Printf("[emulator]Ignoring global dump: %v", err)
+			return nil
+		}
+		e.globals = *g
+	}
+	return nil
+}
+
+// soundDumps answers SNDR for a sound slot, an edit buffer or, for 40 00,
+// every sound of banks A–H.
+func (e *Emulator) soundDumps(bank byte, program byte) [][]byte {
+	devID := e.globals.DeviceID
+	switch {
+	case bank < soundBanks && program < 128:
+		return [][]byte{snddMessage(devID, bank, program, e.sounds[bank][program])}
+	case bank == editBufferBank:
+		if buf := e.editBufferAt(program); buf != nil {
+			return [][]byte{snddMessage(devID, bank, program, buf)}
+		}
+	case bank == allSoundsBank && program == 0:
+		frames := make([][]byte, 0, allSoundsCount)
+		for b := range e.sounds {
+			for p, sdata := range e.sounds[b] {
+				frames = append(frames, snddMessage(devID, byte(b), byte(p), sdata))
+			}
+		}
+		return frames
+	}
+	return nil
+}
+
+// editBufferAt returns the edit buffer at location 7F nn of SNDR/SNDD, or nn
+// of SNDP. Location 0 is the Sound Mode Edit Buffer unless the Blofeld is in
+// Multi mode; the others are the instrument edit buffers of the Multi.
+func (e *Emulator) editBufferAt(location byte) []byte {
+	if location >= multiInstruments {
+		return nil
+	}
+	if location == 0 && e.globals.MultiMode == 0 {
+		return e.editBuffer
+	}
+	return e.multiBuffers[location]
+}
+
+func (e *Emulator) storeSound(msg []byte) error {
+	sdata, err := checkSNDD(msg)
+	if err != nil {
+		return err
+	}
+
+	bank, program := msg[5], msg[6]
+	switch {
+	case bank < soundBanks && program < 128:
+		copy(e.sounds[bank][program], sdata)
+	case bank == editBufferBank && e.editBufferAt(program) != nil:
+		copy(e.editBufferAt(program), sdata)
+	default:
+		return fmt.Errorf("invalid location %02X %02X", bank, program)
+	}
+	return nil
+}
+
+func (e *Emulator) setParameter(msg []byte) error {
+	if len(msg) != 10 {
+		return fmt.Errorf("unexpected SNDP size %d (want 10)", len(msg))
+	}
+
+	buf := e.editBufferAt(msg[5])
+	if buf == nil {
+		return fmt.Errorf("invalid location %d", msg[5])
+	}
+	index := int(msg[6])<<7 | int(msg[7])
+	if index >= PatchSize {
+		return fmt.Errorf("parameter index %d out of range", index)
+	}
+	buf[index] = msg[8] & 0x7F
+	return nil
+}
+
+// Load fills the sound memory from a backup. Sounds that failed validation
+// are skipped. It returns the number of loaded sounds.
+func (e *Emulator) Load(archive *SoundArchive) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	loaded := 0
+	for _, s := range archive.Sounds {
+		if s.Error != "" {
+			continue
+		}
+		bank, err := bankToByte(s.Bank)
+		if err != nil {
+			return loaded, err
+		}
+		if s.Program < 1 || s.Program > 128 {
+			return loaded, fmt.Errorf("program must be in range 1–128, got %d", s.Program)
+		}
+		sdata, err := checkSNDD(s.SysEx)
+		if err != nil {
+			return loaded, fmt.Errorf("%s%03d: %w", s.Bank, s.Program, err)
+		}
+		copy(e.sounds[bank][s.Program-1], sdata)
+		loaded++
+	}
+	return loaded, nil
+}
+
+// virtualPortDriver is implemented by MIDI drivers that can create virtual
+// ports, like rtmididrv on ALSA.
+type virtualPortDriver interface {
+	OpenVirtualIn(name string) (drivers.In, error)
+	OpenVirtualOut(name string) (drivers.Out, error)
+}
+
+// emulate runs an Emulator on a pair of virtual MIDI ports until interrupted,
+// so the other commands and the MCP server can be used without a Blofeld.
+func emulate(cfg Config, args []string) {
+	fs := flag.NewFlagSet("emulate", flag.ExitOnError)
+	name := fs.String("name", "Blofeld Emulator", "name of the virtual MIDI ports")
+	load := fs.String("load", "", "backup file to fill the sound memory from")
+	_ = fs.Parse(args)
+
+	emu := NewEmulator(cfg.DeviceID)
+	if *load != "" {
+		archive, err := ReadSoundArchive(*load)
+		if err != nil {
+			log.Fatalf("failed to read %s: %v", *load, err)
+		}
+		n, err := emu.Load(archive)
+		if err != nil {
+			log.Fatalf("failed to load %s: %v", *load, err)
+		}
+		log.Printf("Loaded %d sounds from %s", n, *load)
+	}
+
+	drv, ok := drivers.Get().(virtualPortDriver)
+	if !ok {
+		log.Fatalf("the MIDI driver does not support virtual ports")
+	}
+	defer drivers.Close()
+
+	in, err := drv.OpenVirtualIn(*name)
+	if err != nil {
+		log.Fatalf("failed to open virtual MIDI input: %v", err)
+	}
+	out, err := drv.OpenVirtualOut(*name)
+	if err != nil {
+		log.Fatalf("failed to open virtual MIDI output: %v", err)
+	}
+
+	t := &rtmidiTransport{in: in, out: out}
+	stop, err := t.ListenSysEx(func(msg []byte) {
+		for _, reply := range emu.Handle(msg) {
+			if err := t.Send(reply); err != nil {
+				log.Printf("failed to send reply: %v", err)
+			}
+		}
+	})
+	if err != nil {
+		log.Fatalf("failed to listen on %s: %v", *name, err)
+	}
+	defer stop()
+
+	log.Printf("Emulating a Blofeld with device ID 0x%02X on virtual port %q, press Ctrl+C to stop", cfg.DeviceID, *name)
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	<-interrupt
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestEmulatorSounds(t *testing.T) {
+	emu := NewEmulator(0x00)
+	blo := NewBlofeld(0x00, emu.Transport())
+
+	sound := testSound(t, "Emulated")
+	if err := blo.SendPatch("C", 5, sound, 0x00); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+	if p, _, err := blo.RequestPatchDump("C", 5); err != nil || p.Name != sound.Name {
+		t.Fatalf("expected %q, got %+v, %v", sound.Name, p, err)
+	}
+	if p, _, err := blo.RequestPatchDump("D", 1); err != nil || strings.TrimRight(p.Name, " ") != "Init" {
+		t.Fatalf("expected the init sound, got %+v, %v", p, err)
+	}
+
+	if err := blo.SendInstrument(3, sound); err != nil {
+		t.Fatalf("failed to send instrument: %v", err)
+	}
+	if err := blo.SetInstrumentParameter(3, nameIdx, 'X'); err != nil {
+		t.Fatalf("failed to set parameter: %v", err)
+	}
+	if err := blo.SaveInstrument(3, "H", 128); err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+	p, _, err := blo.RequestPatchDump("H", 128)
+	if err != nil || p.Name != "X"+sound.Name[1:] {
+		t.Fatalf("expected the changed instrument in H128, got %+v, %v", p, err)
+	}
+	if p, _, err := blo.RequestEditBuffer(); err != nil || strings.TrimRight(p.Name, " ") != "Init" {
+		t.Errorf("instrument 3 changed the Sound Mode Edit Buffer: %+v, %v", p, err)
+	}
+
+	archive, err := blo.RequestAllSounds(nil)
+	if err != nil {
+		t.Fatalf("failed to back up: %v", err)
+	}
+	if len(archive.Sounds) != allSoundsCount || len(archive.Invalid()) != 0 {
+		t.Fatalf("got %d sounds, %d invalid", len(archive.Sounds), len(archive.Invalid()))
+	}
+	if s := archive.Sounds[2*128+4]; s.Name != NormalizeName("Emulated") {
+		t.Errorf("expected C005 in the backup, got %+v", s)
+	}
+}
+
+func TestEditBuffers(t *testing.T) {
+	emu := NewEmulator(0x00)
+	tr := emu.Transport()
+	blo := NewBlofeld(0x00, tr)
+
+	sound := testSound(t, "Edit Buffer")
+	if err := blo.SendEditBuffer(sound); err != nil {
+		t.Fatalf("failed to send edit buffer: %v", err)
+	}
+	if err := blo.SendInstrument(16, sound); err != nil {
+		t.Fatalf("failed to send instrument: %v", err)
+	}
+	if p, _, err := blo.RequestEditBuffer(); err != nil || p.Name != sound.Name {
+		t.Errorf("expected %q in the edit buffer, got %+v, %v", sound.Name, p, err)
+	}
+	if p, _, err := blo.RequestInstrument(16); err != nil || p.Name != sound.Name {
+		t.Errorf("expected %q in instrument 16, got %+v, %v", sound.Name, p, err)
+	}
+
+	// Edit buffers are addressed as bank 7F, location instrument-1.
+	sent := tr.Sent()
+	if len(sent) != 4 {
+		t.Fatalf("expected 4 messages, got %d", len(sent))
+	}
+	for i, loc := range [][]byte{{0x7F, 0x00}, {0x7F, 0x0F}} {
+		if sent[i][4] != 0x10 || !bytes.Equal(sent[i][5:7], loc) {
+			t.Errorf("SNDD %d sent to % X, expected % X", i, sent[i][4:7], loc)
+		}
+	}
+	for i, want := range [][]byte{
+		{0xF0, 0x3E, 0x13, 0x00, 0x00, 0x7F, 0x00, 0xF7},
+		{0xF0, 0x3E, 0x13, 0x00, 0x00, 0x7F, 0x0F, 0xF7},
+	} {
+		if !bytes.Equal(sent[2+i], want) {
+			t.Errorf("sent SNDR % X, expected % X", sent[2+i], want)
+		}
+	}
+
+	for _, instrument := range []int{0, 17} {
+		if err := blo.SendInstrument(instrument, sound); err == nil {
+			t.Errorf("instrument %d was accepted", instrument)
+		}
+		if _, _, err := blo.RequestInstrument(instrument); err == nil {
+			t.Errorf("instrument %d was requested", instrument)
+		}
+	}
+	if n := len(tr.Sent()); n != 4 {
+		t.Errorf("invalid instruments sent %d messages", n-4)
+	}
+}
+
+func TestEmulatorGlobals(t *testing.T) {
+	emu := NewEmulator(0x12)
+	blo := NewBlofeld(0x00, emu.Transport())
+
+	g, err := blo.DiscoverGlobals()
+	if err != nil {
+		t.Fatalf("failed to discover: %v", err)
+	}
+	if g.DeviceID != 0x12 || g.MIDIChannel != 5 {
+		t.Errorf("unexpected globals %+v", g)
+	}
+	if err := g.Validate(); err != nil {
+		t.Errorf("emulator globals are invalid: %v", err)
+	}
+
+	blo.SetDeviceID(g.DeviceID)
+	g.Volume = 90
+	if err := blo.SendGlobals(g); err != nil {
+		t.Fatalf("failed to send globals: %v", err)
+	}
+	if got, _, err := blo.RequestGlobalDump(); err != nil || got.Volume != 90 {
+		t.Errorf("expected volume 90, got %+v, %v", got, err)
+	}
+
+	req := []byte{0xF0, 0x3E, 0x13, 0x00, 0x04, 0xF7}
+	if replies := emu.Handle(req); len(replies) != 0 {
+		t.Errorf("answered a request for another device ID")
+	}
+}
+
+func TestSweepMorph(t *testing.T) {
+	emu := NewEmulator(0x00)
+	tr := emu.Transport()
+	blo := NewBlofeld(0x00, tr)
+
+	from, to := testSound(t, "Sweep"), testSound(t, "Sweep")
+	from.Filters[0].Cutoff, to.Filters[0].Cutoff = 20, 120
+	from.Oscillators[0].Shape, to.Oscillators[0].Shape = 1, 2
+
+	sent, err := blo.SweepMorph(from, to, SweepOptions{Instrument: 3, From: 0, To: 1, Steps: 4})
+	if err != nil {
+		t.Fatalf("failed to sweep: %v", err)
+	}
+
+	// The start sound as SNDD, then SNDP for the changes of each step only:
+	// the cutoff every step, the shape once at the halfway point.
+	sndp := func(index int, value byte) []byte {
+		msg, _ := sndpMessage(0x00, 0x02, index, value)
+		return msg
+	}
+	cutoff, shape := filterFieldMapping[0].cutoff, oscFieldMapping[0].shape
+	want := [][]byte{
+		sndp(cutoff, 45),
+		sndp(shape, 2), sndp(cutoff, 70),
+		sndp(cutoff, 95),
+		sndp(cutoff, 120),
+	}
+	msgs := tr.Sent()
+	if sent != 1+len(want) || len(msgs) != sent {
+		t.Fatalf("reported %d messages and sent %d, want %d", sent, len(msgs), 1+len(want))
+	}
+	if msgs[0][4] != 0x10 || !bytes.Equal(msgs[0][5:7], []byte{0x7F, 0x02}) {
+		t.Errorf("start sound sent as % X", msgs[0][:7])
+	}
+	for i, w := range want {
+		if !bytes.Equal(msgs[1+i], w) {
+			t.Errorf("message %d is % X, want % X", 1+i, msgs[1+i], w)
+		}
+	}
+
+	p, _, err := blo.RequestInstrument(3)
+	if err != nil {
+		t.Fatalf("failed to read instrument: %v", err)
+	}
+	if p.Filters[0].Cutoff != 120 || p.Oscillators[0].Shape != 2 {
+		t.Errorf("instrument ended with cutoff %d, shape %v", p.Filters[0].Cutoff, p.Oscillators[0].Shape)
+	}
+
+	// An invalid endpoint is rejected before anything is sent.
+	to.Oscillators[2].Shape = 5 // oscillator 3 has no wavetables
+	before := len(tr.Sent())
+	if _, err := blo.SweepMorph(from, to, SweepOptions{Instrument: 3, To: 1, Steps: 4}); err == nil {
+		t.Error("expected an error for an invalid oscillator 3 shape")
+	}
+	if n := len(tr.Sent()) - before; n != 0 {
+		t.Errorf("invalid sweep sent %d messages", n)
+	}
+}
+
+func TestWriteUpdate(t *testing.T) {
+	emu := NewEmulator(0x00)
+	tr := emu.Transport()
+	blo := NewBlofeld(0x00, tr)
+
+	old := testSound(t, "Update")
+	if err := blo.SendInstrument(2, old); err != nil {
+		t.Fatalf("failed to send instrument: %v", err)
+	}
+	updated, err := ApplyPatchUpdate(old, []byte(`{"filters": [{"cutoff": 40}]}`), "raw")
+	if err != nil {
+		t.Fatalf("failed to apply update: %v", err)
+	}
+
+	// An edit buffer only gets SNDP for the changed index.
+	before := len(tr.Sent())
+	n, err := blo.WriteUpdate(patchLocation{Instrument: 2}, old, updated)
+	if err != nil {
+		t.Fatalf("failed to write edit buffer: %v", err)
+	}
+	sent := tr.Sent()[before:]
+	want, _ := sndpMessage(0x00, 0x01, filterFieldMapping[0].cutoff, 40)
+	if n != 1 || len(sent) != 1 || !bytes.Equal(sent[0], want) {
+		t.Errorf("reported %d messages, sent % X, want % X", n, sent, want)
+	}
+
+	// A stored slot gets the whole sound as SNDD.
+	before = len(tr.Sent())
+	n, err = blo.WriteUpdate(patchLocation{Bank: "B", Program: 7}, old, updated)
+	if err != nil {
+		t.Fatalf("failed to write slot: %v", err)
+	}
+	sent = tr.Sent()[before:]
+	want, _ = updated.ToSNDD(0x00, 0x01, 0x06)
+	if n != 1 || len(sent) != 1 || !bytes.Equal(sent[0], want) {
+		t.Errorf("reported %d messages, sent %d, want the SNDD for B007", n, len(sent))
+	}
+
+	for _, read := range []func() (*Patch, byte, error){
+		func() (*Patch, byte, error) { return blo.RequestInstrument(2) },
+		func() (*Patch, byte, error) { return blo.RequestPatchDump("B", 7) },
+	} {
+		if p, _, err := read(); err != nil || p.Filters[0].Cutoff != 40 {
+			t.Errorf("expected cutoff 40 on the emulator, got %+v, %v", p, err)
+		}
+	}
+}
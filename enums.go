@@ -0,0 +1,292 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Enumerated SDATA values are stored as raw bytes but marshal to JSON as the
+// names used in the spec tables, so clients do not have to look them up.
+// Unmarshalling accepts either a name (case-insensitive, spaces and
+// underscores ignored) or the raw number. Values without a name, e.g. from a
+// newer firmware, marshal as plain numbers so nothing is lost.
+
+func enumName(v byte, names []string) string {
+	if int(v) < len(names) {
+		return names[v]
+	}
+	return strconv.Itoa(int(v))
+}
+
+func marshalEnum(v byte, names []string) ([]byte, error) {
+	if int(v) < len(names) {
+		return json.Marshal(names[v])
+	}
+	return json.Marshal(v)
+}
+
+func unmarshalEnum(data []byte, names []string, kind string) (byte, error) {
+	var n int
+	if err := json.Unmarshal(data, &n); err == nil {
+		if n < 0 || n > 127 {
+			return 0, fmt.Errorf("%s must be in range 0–127, got %d", kind, n)
+		}
+		return byte(n), nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return 0, fmt.Errorf("%s must be a name or a number, got %s", kind, data)
+	}
+	return parseEnum(s, names, kind)
+}
+
+func parseEnum(s string, names []string, kind string) (byte, error) {
+	key := enumKey(s)
+	for i, name := range names {
+		if enumKey(name) == key {
+			return byte(i), nil
+		}
+	}
+
+	if n, err := strconv.Atoi(strings.TrimSpace(s)); err == nil && n >= 0 && n <= 127 {
+		return byte(n), nil
+	}
+	return 0, fmt.Errorf("unknown %s %q (valid: %s)", kind, s, strings.Join(names, ", "))
+}
+
+func enumKey(s string) string {
+	s = strings.ToLower(s)
+	return strings.NewReplacer(" ", "", "_", "").Replace(s)
+}
+
+// OscShape is an oscillator shape or wave (spec 4.1). Oscillator 3 only
+// features shapes 0 (off) to 4 (Sine).
+type OscShape byte
+
+var oscShapeNames = []string{
+	"off", "Pulse", "Saw", "Triangle", "Sine", "Alt 1", "Alt 2", "Resonant",
+	"Resonant2", "MalletSyn", "Sqr-Sweep", "Bellish", "Pul-Sweep", "Saw-Sweep",
+	"MellowSaw", "Feedback", "Add Harm", "Reso 3 HP", "Wind Syn", "High Harm",
+	"Clipper", "Organ Syn", "SquareSaw", "Formant 1", "Polated", "Transient",
+	"ElectricP", "Robotic", "StrongHrm", "PercOrgan", "ClipSweep", "ResoHarms",
+	"2 Echoes", "Formant 2", "FmntVocal", "MicroSync", "Micro PWM", "Glassy",
+	"Square HP", "SawSync 1", "SawSync 2", "SawSync 3", "PulSync 1",
+	"PulSync 2", "PulSync 3", "SinSync 1", "SinSync 2", "SinSync 3",
+	"PWM Pulse", "PWM Saw", "Fuzz Wave", "Distorted", "HeavyFuzz", "Fuzz Sync",
+	"K+Strong1", "K+Strong2", "K+Strong3", "1-2-3-4-5", "19/twenty",
+	"Wavetrip1", "Wavetrip2", "Wavetrip3", "Wavetrip4", "MaleVoice",
+	"Low Piano", "ResoSweep", "Xmas Bell", "FM Piano", "Fat Organ", "Vibes",
+	"Chorus 2", "True PWM", "UpperWaves",
+}
+
+func (v OscShape) String() string { return enumName(byte(v), oscShapeNames) }
+
+func (v OscShape) MarshalJSON() ([]byte, error) { return marshalEnum(byte(v), oscShapeNames) }
+
+func (v *OscShape) UnmarshalJSON(data []byte) error {
+	b, err := unmarshalEnum(data, oscShapeNames, "oscillator shape")
+	*v = OscShape(b)
+	return err
+}
+
+// FMSource is an oscillator or filter FM source (spec 4.2).
+type FMSource byte
+
+var fmSourceNames = []string{
+	"off", "Osc 1", "Osc 2", "Osc 3", "Noise", "LFO 1", "LFO 2", "LFO 3",
+	"FilterEnv", "AmpEnv", "Env3", "Env4",
+}
+
+func (v FMSource) String() string { return enumName(byte(v), fmSourceNames) }
+
+func (v FMSource) MarshalJSON() ([]byte, error) { return marshalEnum(byte(v), fmSourceNames) }
+
+func (v *FMSource) UnmarshalJSON(data []byte) error {
+	b, err := unmarshalEnum(data, fmSourceNames, "FM source")
+	*v = FMSource(b)
+	return err
+}
+
+// FilterType is a filter type (spec 4.4).
+type FilterType byte
+
+var filterTypeNames = []string{
+	"Bypass", "LP 24dB", "LP 12dB", "BP 24dB", "BP 12dB", "HP 24dB", "HP 12dB",
+	"Notch24dB", "Notch12dB", "Comb+", "Comb-", "PPG LP",
+}
+
+func (v FilterType) String() string { return enumName(byte(v), filterTypeNames) }
+
+func (v FilterType) MarshalJSON() ([]byte, error) { return marshalEnum(byte(v), filterTypeNames) }
+
+func (v *FilterType) UnmarshalJSON(data []byte) error {
+	b, err := unmarshalEnum(data, filterTypeNames, "filter type")
+	*v = FilterType(b)
+	return err
+}
+
+// LFOShape is an LFO waveform (spec 4.5).
+type LFOShape byte
+
+var lfoShapeNames = []string{
+	"Sine", "Triangle", "Square", "Saw", "Random", "S&H",
+}
+
+func (v LFOShape) String() string { return enumName(byte(v), lfoShapeNames) }
+
+func (v LFOShape) MarshalJSON() ([]byte, error) { return marshalEnum(byte(v), lfoShapeNames) }
+
+func (v *LFOShape) UnmarshalJSON(data []byte) error {
+	b, err := unmarshalEnum(data, lfoShapeNames, "LFO shape")
+	*v = LFOShape(b)
+	return err
+}
+
+// ModSource is a modulation source (spec 4.7).
+type ModSource byte
+
+var modSourceNames = []string{
+	"off", "LFO 1", "LFO1*MW", "LFO 2", "LFO2*Press", "LFO 3", "FilterEnv",
+	"AmpEnv", "Env3", "Env4", "Keytrack", "Velocity", "Rel. Velo", "Pressure",
+	"Poly Press", "Pitch Bend", "Mod Wheel", "Sustain", "Foot Ctrl",
+	"BreathCtrl", "Control W", "Control X", "Control Y", "Control Z",
+	"Unisono V.", "Modifier 1", "Modifier 2", "Modifier 3", "Modifier 4",
+	"minimum", "MAXIMUM",
+}
+
+func (v ModSource) String() string { return enumName(byte(v), modSourceNames) }
+
+func (v ModSource) MarshalJSON() ([]byte, error) { return marshalEnum(byte(v), modSourceNames) }
+
+func (v *ModSource) UnmarshalJSON(data []byte) error {
+	b, err := unmarshalEnum(data, modSourceNames, "modulation source")
+	*v = ModSource(b)
+	return err
+}
+
+// ModDest is a modulation matrix destination (spec 4.8).
+type ModDest byte
+
+var modDestNames = []string{
+	"Pitch", "O1 Pitch", "O1 FM", "O1 PW/Wave", "O2 Pitch", "O2 FM",
+	"O2 PW/Wave", "O3 Pitch", "O3 FM", "O3 PW", "O1 Level", "O1 Balance",
+	"O2 Level", "O2 Balance", "O3 Level", "O3 Balance", "RMod Level",
+	"RMod Bal.", "NoiseLevel", "Noise Bal.", "F1 Cutoff", "F1 Reson.", "F1 FM",
+	"F1 Drive", "F1 Pan", "F2 Cutoff", "F2 Reson.", "F2 FM", "F2 Drive",
+	"F2 Pan", "Volume", "LFO1Speed", "LFO2Speed", "LFO3Speed", "FE Attack",
+	"FE Decay", "FE Sustain", "FE Release", "AE Attack", "AE Decay",
+	"AE Sustain", "AE Release", "E3 Attack", "E3 Decay", "E3 Sustain",
+	"E3 Release", "E4 Attack", "E4 Decay", "E4 Sustain", "E4 Release",
+	"M1 Amount", "M2 Amount", "M3 Amount", "M4 Amount",
+}
+
+func (v ModDest) String() string { return enumName(byte(v), modDestNames) }
+
+func (v ModDest) MarshalJSON() ([]byte, error) { return marshalEnum(byte(v), modDestNames) }
+
+func (v *ModDest) UnmarshalJSON(data []byte) error {
+	b, err := unmarshalEnum(data, modDestNames, "modulation destination")
+	*v = ModDest(b)
+	return err
+}
+
+// ModOperator is a modifier operator (spec 4.9).
+type ModOperator byte
+
+var modOperatorNames = []string{
+	"+", "-", "*", "AND", "OR", "XOR", "MAX", "min",
+}
+
+func (v ModOperator) String() string { return enumName(byte(v), modOperatorNames) }
+
+func (v ModOperator) MarshalJSON() ([]byte, error) { return marshalEnum(byte(v), modOperatorNames) }
+
+func (v *ModOperator) UnmarshalJSON(data []byte) error {
+	b, err := unmarshalEnum(data, modOperatorNames, "modifier operator")
+	*v = ModOperator(b)
+	return err
+}
+
+// DriveCurve is a filter drive curve (spec 4.11).
+type DriveCurve byte
+
+var driveCurveNames = []string{
+	"Clipping", "Tube", "Hard", "Medium", "Soft", "Pickup 1", "Pickup 2",
+	"Rectifier", "Square", "Binary", "Overflow", "Sine Shaper", "Osc 1 Mod",
+}
+
+func (v DriveCurve) String() string { return enumName(byte(v), driveCurveNames) }
+
+func (v DriveCurve) MarshalJSON() ([]byte, error) { return marshalEnum(byte(v), driveCurveNames) }
+
+func (v *DriveCurve) UnmarshalJSON(data []byte) error {
+	b, err := unmarshalEnum(data, driveCurveNames, "drive curve")
+	*v = DriveCurve(b)
+	return err
+}
+
+// EnvMode is the mode part of an envelope mode byte (spec 4.12, mmmmm).
+type EnvMode byte
+
+var envModeNames = []string{"ADSR", "ADS1DS2R", "One Shot", "Loop S1S2", "Loop All"}
+
+func (v EnvMode) String() string { return enumName(byte(v), envModeNames) }
+
+func (v EnvMode) MarshalJSON() ([]byte, error) { return marshalEnum(byte(v), envModeNames) }
+
+func (v *EnvMode) UnmarshalJSON(data []byte) error {
+	b, err := unmarshalEnum(data, envModeNames, "envelope mode")
+	*v = EnvMode(b)
+	return err
+}
+
+// EnvTrigger is the trigger part of an envelope mode byte (spec 4.12, tt).
+type EnvTrigger byte
+
+var envTriggerNames = []string{"normal", "single"}
+
+func (v EnvTrigger) String() string { return enumName(byte(v), envTriggerNames) }
+
+func (v EnvTrigger) MarshalJSON() ([]byte, error) { return marshalEnum(byte(v), envTriggerNames) }
+
+func (v *EnvTrigger) UnmarshalJSON(data []byte) error {
+	b, err := unmarshalEnum(data, envTriggerNames, "envelope trigger")
+	*v = EnvTrigger(b)
+	return err
+}
+
+// ArpStepKind is the step part of an arpeggiator pattern step (sss).
+type ArpStepKind byte
+
+var arpStepKindNames = []string{"normal", "pause", "previous", "first", "last", "first+last", "chord", "random"}
+
+func (v ArpStepKind) String() string { return enumName(byte(v), arpStepKindNames) }
+
+func (v ArpStepKind) MarshalJSON() ([]byte, error) { return marshalEnum(byte(v), arpStepKindNames) }
+
+func (v *ArpStepKind) UnmarshalJSON(data []byte) error {
+	b, err := unmarshalEnum(data, arpStepKindNames, "arp step")
+	*v = ArpStepKind(b)
+	return err
+}
+
+// Category is a sound category (spec 4.16).
+type Category byte
+
+var categoryNames = []string{
+	"Init", "Arp", "Atmo", "Bass", "Drum", "FX", "Keys", "Lead", "Mono", "Pad",
+	"Perc", "Poly", "Seq",
+}
+
+func (v Category) String() string { return enumName(byte(v), categoryNames) }
+
+func (v Category) MarshalJSON() ([]byte, error) { return marshalEnum(byte(v), categoryNames) }
+
+func (v *Category) UnmarshalJSON(data []byte) error {
+	b, err := unmarshalEnum(data, categoryNames, "category")
+	*v = Category(b)
+	return err
+}
@@ -1,55 +1,133 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
-
-	"gitlab.com/gomidi/midi/v2"
 )
 
-func getPatch(inPortIdx int, portIdx int, blo *Blofeld, blofeldChannel uint8) {
+func getPatch(portIdx int, blo *Blofeld, blofeldChannel uint8, args []string) {
+	fs := flag.NewFlagSet("get", flag.ExitOnError)
+	bank := fs.String("bank", "H", "bank to read (A-H)")
+	program := fs.Int("program", 128, "program to read (1-128)")
+	out := fs.String("out", "", "file to write (default stdout)")
+	format := fs.String("format", "raw", "output format: raw, display or syx")
+	_ = fs.Parse(args)
 
 	log.Printf("Connected to Blofeld on port index %d (channel %d).\n", portIdx, blofeldChannel+1)
 
-	var Bank = "H"
-	var Program = 128
-
-	p, devID, err := blo.RequestPatchDump(midi.GetInPorts()[inPortIdx], Bank, Program)
+	p, devID, err := blo.RequestPatchDump(*bank, *program)
 	if err != nil {
 		log.Fatalf("failed to read patch: %v", err)
 	}
 	log.Println("Patch name", p.Name)
-	log.Printf("Read patch from Bank %s, Program %d (device 0x%02X): %+v\n", Bank, Program, devID, p)
+	log.Printf("Read patch from Bank %s, Program %d (device 0x%02X): %+v\n", *bank, *program, devID, p)
 
-	asJson, err := json.MarshalIndent(&p, "", "  ")
-	if err != nil {
-		log.Fatalf("failed to marshal patch to JSON: %v", err)
+	var buf bytes.Buffer
+	switch *format {
+	case "raw", "json", "display":
+		asJson, err := marshalPatch(p, *format)
+		if err != nil {
+			log.Fatalf("failed to marshal patch to JSON: %v", err)
+		}
+
+		buf.Write(asJson)
+		buf.WriteByte('\n')
+	case "syx":
+		bankByte, _ := bankToByte(*bank)
+		sound := SyxSound{Bank: bankByte, Program: byte(*program - 1), Patch: p}
+		if err := WriteSyx(&buf, devID, []SyxSound{sound}); err != nil {
+			log.Fatalf("failed to write patch as SysEx: %v", err)
+		}
+	default:
+		log.Fatalf("unknown format %q (want raw, display or syx)", *format)
 	}
 
-	fmt.Println(string(asJson))
+	if *out == "" {
+		_, err = os.Stdout.Write(buf.Bytes())
+	} else {
+		err = os.WriteFile(*out, buf.Bytes(), 0o644)
+	}
+	if err != nil {
+		log.Fatalf("failed to write patch: %v", err)
+	}
 }
 
-func setPatch(inPortIdx int, portIdx int, blo *Blofeld, blofeldChannel uint8, devID byte) {
+func setPatch(portIdx int, blo *Blofeld, blofeldChannel uint8, devID byte, args []string) {
+	fs := flag.NewFlagSet("set", flag.ExitOnError)
+	bank := fs.String("bank", "H", "bank to write (A-H)")
+	program := fs.Int("program", 128, "program to write (1-128)")
+	in := fs.String("in", "", "file to read (default stdin)")
+	format := fs.String("format", "raw", "input format: raw, display or syx")
+	_ = fs.Parse(args)
+
 	log.Printf("Connected to Blofeld on port index %d (channel %d).\n", portIdx, blofeldChannel+1)
 
+	var r io.Reader = os.Stdin
+	if *in != "" {
+		f, err := os.Open(*in)
+		if err != nil {
+			log.Fatalf("failed to open patch file: %v", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
 	patch := &Patch{}
 
-	asJson, err := io.ReadAll(os.Stdin)
-	if err != nil {
-		log.Fatalf("failed to read patch JSON from stdin: %v", err)
-	}
+	switch *format {
+	case "raw", "json", "display":
+		asJson, err := io.ReadAll(r)
+		if err != nil {
+			log.Fatalf("failed to read patch JSON: %v", err)
+		}
 
-	if err := json.Unmarshal(asJson, patch); err != nil {
-		log.Fatalf("failed to unmarshal patch JSON: %v", err)
+		if err := unmarshalPatch(asJson, *format, patch); err != nil {
+			log.Fatalf("failed to unmarshal patch JSON: %v", err)
+		}
+	case "syx":
+		sounds, err := ReadSyx(r)
+		if err != nil {
+			log.Fatalf("failed to read patch SysEx: %v", err)
+		}
+		if len(sounds) != 1 {
+			log.Fatalf("expected a single sound, got %d (use restore for banks)", len(sounds))
+		}
+		patch = sounds[0].Patch
+	default:
+		log.Fatalf("unknown format %q (want raw, display or syx)", *format)
 	}
 
-	var Bank = "H"
-	var Program = 128
+	if err := patch.Validate(); err != nil {
+		log.Fatalf("invalid patch: %v", err)
+	}
 
-	if err := blo.SendPatch(Bank, Program, patch, devID); err != nil {
+	// The stored location of a .syx sound is ignored; it always goes to the target slot.
+	if err := blo.SendPatch(*bank, *program, patch, devID); err != nil {
 		log.Fatalf("failed to send patch: %v", err)
 	}
 }
+
+func getGlobals(blo *Blofeld) {
+	g, err := blo.DiscoverGlobals()
+	if err != nil {
+		log.Fatalf("failed to read global data: %v", err)
+	}
+
+	if ch, ok := g.Channel(); ok {
+		log.Printf("Blofeld reports device ID 0x%02X, MIDI channel %d\n", g.DeviceID, ch+1)
+	} else {
+		log.Printf("Blofeld reports device ID 0x%02X, MIDI channel omni\n", g.DeviceID)
+	}
+
+	asJson, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		log.Fatalf("failed to marshal global data to JSON: %v", err)
+	}
+
+	fmt.Println(string(asJson))
+}
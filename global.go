@@ -0,0 +1,263 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"gitlab.com/gomidi/midi/v2"
+)
+
+const GlobalSize = 72 // GDATA payload size, see Blofeld spec 3.2
+
+// broadcastDeviceID addresses every Blofeld regardless of its device ID.
+const broadcastDeviceID byte = 0x7F
+
+type GlobalSound struct {
+	Bank  byte `json:"bank"`  // 0..7 = A..H
+	Sound byte `json:"sound"` // 0..127 = 1..128
+}
+
+type GlobalSettings struct {
+	MultiMode byte `json:"multi_mode"`
+
+	// Bank/Sound pairs (GDATA 2–33)
+	Sounds [16]GlobalSound `json:"sounds"`
+
+	AutoEdit    byte `json:"auto_edit"`
+	MIDIChannel byte `json:"midi_channel"` // 0 = omni, 1..16
+	DeviceID    byte `json:"device_id"`
+	PopupTime   byte `json:"popup_time"`
+	Contrast    byte `json:"contrast"`
+	MasterTune  byte `json:"master_tune"` // 54..74 = 430..450 Hz
+	Transpose   byte `json:"transpose"`   // 52..76 = -12..+12
+	CtrlSend    byte `json:"ctrl_send"`
+	CtrlReceive byte `json:"ctrl_receive"`
+	Clock       byte `json:"clock"`
+	VelCurve    byte `json:"vel_curve"`
+
+	// Control W–Z controller assignments
+	ControlW byte `json:"control_w"`
+	ControlX byte `json:"control_x"`
+	ControlY byte `json:"control_y"`
+	ControlZ byte `json:"control_z"`
+
+	Volume         byte `json:"volume"`
+	CategoryFilter byte `json:"category_filter"`
+
+	// Raw is the GDATA the settings were parsed from. ToGDATA starts from
+	// it, so reserved bytes survive a read-modify-write.
+	Raw []byte `json:"raw,omitempty"`
+}
+
+// Index mappings into the GDATA payload (see Blofeld spec 3.2).
+const (
+	globalMultiModeIdx   = 1
+	globalSoundsStartIdx = 2
+	globalAutoEditIdx    = 35
+	globalChannelIdx     = 36
+	globalDeviceIDIdx    = 37
+	globalPopupTimeIdx   = 38
+	globalContrastIdx    = 39
+	globalMasterTuneIdx  = 40
+	globalTransposeIdx   = 41
+	globalCtrlSendIdx    = 44
+	globalCtrlReceiveIdx = 45
+	globalClockIdx       = 48
+	globalVelCurveIdx    = 50
+	globalControlWIdx    = 51
+	globalControlXIdx    = 52
+	globalControlYIdx    = 53
+	globalControlZIdx    = 54
+	globalVolumeIdx      = 55
+	globalCatFilterIdx   = 56
+)
+
+// Channel returns the 0-based MIDI channel the Blofeld listens on, or false
+// when it is set to omni.
+func (g *GlobalSettings) Channel() (uint8, bool) {
+	if g.MIDIChannel == 0 || g.MIDIChannel > 16 {
+		return 0, false
+	}
+	return g.MIDIChannel - 1, true
+}
+
+func ParseGDATA(data []byte) (*GlobalSettings, error) {
+	if len(data) <= globalCatFilterIdx {
+		return nil, fmt.Errorf("invalid GDATA length %d", len(data))
+	}
+
+	g := &GlobalSettings{Raw: append([]byte(nil), data...)}
+
+	g.MultiMode = data[globalMultiModeIdx]
+	for i := range g.Sounds {
+		base := globalSoundsStartIdx + i*2
+		g.Sounds[i].Bank = data[base]
+		g.Sounds[i].Sound = data[base+1]
+	}
+
+	g.AutoEdit = data[globalAutoEditIdx]
+	g.MIDIChannel = data[globalChannelIdx]
+	g.DeviceID = data[globalDeviceIDIdx]
+	g.PopupTime = data[globalPopupTimeIdx]
+	g.Contrast = data[globalContrastIdx]
+	g.MasterTune = data[globalMasterTuneIdx]
+	g.Transpose = data[globalTransposeIdx]
+	g.CtrlSend = data[globalCtrlSendIdx]
+	g.CtrlReceive = data[globalCtrlReceiveIdx]
+	g.Clock = data[globalClockIdx]
+	g.VelCurve = data[globalVelCurveIdx]
+
+	g.ControlW = data[globalControlWIdx]
+	g.ControlX = data[globalControlXIdx]
+	g.ControlY = data[globalControlYIdx]
+	g.ControlZ = data[globalControlZIdx]
+
+	g.Volume = data[globalVolumeIdx]
+	g.CategoryFilter = data[globalCatFilterIdx]
+
+	return g, nil
+}
+
+// ToGDATA builds the GDATA payload. Bytes that are not mapped to a field
+// are taken from Raw when it holds a complete GDATA block.
+func (g *GlobalSettings) ToGDATA() []byte {
+	data := make([]byte, GlobalSize)
+	if len(g.Raw) == GlobalSize {
+		copy(data, g.Raw)
+	}
+
+	data[globalMultiModeIdx] = g.MultiMode
+	for i, s := range g.Sounds {
+		base := globalSoundsStartIdx + i*2
+		data[base] = s.Bank
+		data[base+1] = s.Sound
+	}
+
+	data[globalAutoEditIdx] = g.AutoEdit
+	data[globalChannelIdx] = g.MIDIChannel
+	data[globalDeviceIDIdx] = g.DeviceID
+	data[globalPopupTimeIdx] = g.PopupTime
+	data[globalContrastIdx] = g.Contrast
+	data[globalMasterTuneIdx] = g.MasterTune
+	data[globalTransposeIdx] = g.Transpose
+	data[globalCtrlSendIdx] = g.CtrlSend
+	data[globalCtrlReceiveIdx] = g.CtrlReceive
+	data[globalClockIdx] = g.Clock
+	data[globalVelCurveIdx] = g.VelCurve
+
+	data[globalControlWIdx] = g.ControlW
+	data[globalControlXIdx] = g.ControlX
+	data[globalControlYIdx] = g.ControlY
+	data[globalControlZIdx] = g.ControlZ
+
+	data[globalVolumeIdx] = g.Volume
+	data[globalCatFilterIdx] = g.CategoryFilter
+
+	return data
+}
+
+// Validate checks every field against the ranges in spec 3.2. It returns
+// ValidationErrors listing all invalid fields, or nil.
+func (g *GlobalSettings) Validate() error {
+	if g.Raw != nil && len(g.Raw) != GlobalSize {
+		return fmt.Errorf("raw GDATA must be %d bytes, got %d", GlobalSize, len(g.Raw))
+	}
+
+	var v validator
+	v.check("multi_mode", g.MultiMode, 0, 1)
+	for i, s := range g.Sounds {
+		v.check(fmt.Sprintf("sounds[%d].bank", i), s.Bank, 0, 7)
+		v.check(fmt.Sprintf("sounds[%d].sound", i), s.Sound, 0, 127)
+	}
+	v.check("auto_edit", g.AutoEdit, 0, 1)
+	v.check("midi_channel", g.MIDIChannel, 0, 16)
+	v.check("device_id", g.DeviceID, 0, 126)
+	v.check("popup_time", g.PopupTime, 1, 127)
+	v.check("contrast", g.Contrast, 0, 127)
+	v.check("master_tune", g.MasterTune, 54, 74)
+	v.check("transpose", g.Transpose, 52, 76)
+	v.check("ctrl_send", g.CtrlSend, 0, 3)
+	v.check("ctrl_receive", g.CtrlReceive, 0, 1)
+	v.check("clock", g.Clock, 0, 1)
+	v.check("vel_curve", g.VelCurve, 0, 8)
+	v.check("control_w", g.ControlW, 0, 120)
+	v.check("control_x", g.ControlX, 0, 120)
+	v.check("control_y", g.ControlY, 0, 120)
+	v.check("control_z", g.ControlZ, 0, 120)
+	v.check("volume", g.Volume, 0, 127)
+	v.check("category_filter", g.CategoryFilter, 0, 13)
+
+	if len(v.errs) > 0 {
+		return v.errs
+	}
+	return nil
+}
+
+// ToGLBD builds a Global Dump message. GLBx messages carry no location bytes.
+func (g *GlobalSettings) ToGLBD(deviceID byte) []byte {
+	gdata := g.ToGDATA()
+
+	out := []byte{0xF0, 0x3E, 0x13, deviceID, 0x14}
+	out = append(out, gdata...)
+	out = append(out, sysexChecksum(gdata), 0xF7)
+
+	return out
+}
+
+func parseGLBD(msg midi.Message) (*GlobalSettings, byte, error) {
+	if len(msg) < 7 || msg[0] != 0xF0 || msg[len(msg)-1] != 0xF7 {
+		return nil, 0, errors.New("message is not a SysEx frame")
+	}
+
+	if msg[1] != 0x3E || msg[2] != 0x13 {
+		return nil, 0, errors.New("not a Waldorf Blofeld SysEx")
+	}
+
+	if msg[4] != 0x14 {
+		return nil, 0, fmt.Errorf("unexpected message type 0x%02X (expected GLBD 0x14)", msg[4])
+	}
+
+	gdata := msg[5 : len(msg)-2]
+	checksum := msg[len(msg)-2]
+
+	chk := sysexChecksum(gdata)
+	if checksum != 0x7F && chk != checksum {
+		return nil, 0, fmt.Errorf("checksum mismatch: expected 0x%02X got 0x%02X", chk, checksum)
+	}
+
+	g, err := ParseGDATA(gdata)
+	return g, msg[3], err
+}
+
+// RequestGlobalDump asks Blofeld for its global parameters and waits for GLBD.
+func (b *Blofeld) RequestGlobalDump() (*GlobalSettings, byte, error) {
+	return b.requestGlobals(b.DeviceID())
+}
+
+// DiscoverGlobals requests the global parameters using the broadcast device
+// ID, so the reply arrives even when the configured device ID is wrong. The
+// returned settings carry the actual device ID and MIDI channel.
+func (b *Blofeld) DiscoverGlobals() (*GlobalSettings, error) {
+	g, _, err := b.requestGlobals(broadcastDeviceID)
+	return g, err
+}
+
+func (b *Blofeld) requestGlobals(devID byte) (*GlobalSettings, byte, error) {
+	log.Printf("Requesting global dump from device ID 0x%02X", devID)
+	req := []byte{0xF0, 0x3E, 0x13, devID, 0x04, 0xF7}
+	msg, err := b.requestSysEx(req, replyTo(devID, 0x14))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to request global dump: %w", err)
+	}
+
+	return parseGLBD(msg)
+}
+
+// SendGlobals transmits the global parameters as a GLBD message.
+func (b *Blofeld) SendGlobals(g *GlobalSettings) error {
+	if err := b.SendSysEx(g.ToGLBD(b.DeviceID())); err != nil {
+		return fmt.Errorf("failed to send global data: %w", err)
+	}
+	return nil
+}
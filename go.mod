@@ -4,7 +4,10 @@ go 1.24.2
 
 toolchain go1.24.10
 
-require github.com/mark3labs/mcp-go v0.43.1
+require (
+	github.com/mark3labs/mcp-go v0.43.1
+	gitlab.com/gomidi/midi/v2 v2.3.16
+)
 
 require (
 	github.com/bahlo/generic-list-go v0.2.0 // indirect
@@ -15,6 +18,5 @@ require (
 	github.com/spf13/cast v1.7.1 // indirect
 	github.com/wk8/go-ordered-map/v2 v2.1.8 // indirect
 	github.com/yosida95/uritemplate/v3 v3.0.2 // indirect
-	gitlab.com/gomidi/midi/v2 v2.3.16 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"strings"
 
 	"gitlab.com/gomidi/midi/v2"
@@ -12,54 +13,94 @@ import (
 )
 
 func main() {
-	const (
-		// Blofeld listens on channel 5 (0-based value 4).
-		blofeldChannel uint8 = 4
-		// Blofeld SysEx device ID often matches the MIDI channel (5 -> 0x04).
-		blofeldDeviceID byte = 0x00
-		nameHint             = "blofeld"
-	)
+	cfg, args, err := loadConfig(os.Args[1:])
+	if err != nil {
+		log.Fatalf("invalid configuration: %v", err)
+	}
+	debugDumps = cfg.Debug
+
+	// The emulator opens its own virtual ports instead of a Blofeld's.
+	if len(args) > 0 && args[0] == "emulate" {
+		emulate(cfg, args[1:])
+		return
+	}
 
 	log.Println("Available MIDI outputs:")
 	log.Print(midi.GetOutPorts().String())
 
-	portIdx, err := findOutPort(nameHint)
+	portIdx, err := findOutPort(cfg.Port)
 	if err != nil {
 		log.Fatalf("could not find Blofeld MIDI out port: %v", err)
 	}
 
-	inPortIdx, err := findInPort(nameHint)
+	inPortIdx, err := findInPort(cfg.Port)
 	if err != nil {
 		log.Fatalf("could not find Blofeld MIDI in port: %v", err)
 	}
 
-	blo, closer, err := OpenBlofeld(blofeldDeviceID, portIdx)
+	blo, closer, err := OpenBlofeld(cfg.DeviceID, portIdx, inPortIdx)
 	if err != nil {
 		log.Fatalf("failed to open Blofeld output: %v", err)
 	}
 	defer closer()
 
-	if len(os.Args) > 1 {
-		switch os.Args[1] {
+	// MIDI channels are 0-based on the wire (channel 5 is 4).
+	blofeldChannel := uint8(cfg.Channel - 1)
+
+	if cfg.Discover {
+		g, err := blo.DiscoverGlobals()
+		if err != nil {
+			log.Fatalf("failed to discover Blofeld settings: %v", err)
+		}
+		blo.SetDeviceID(g.DeviceID)
+		if ch, ok := g.Channel(); ok {
+			blofeldChannel = ch
+		}
+		log.Printf("Discovered device ID 0x%02X, MIDI channel %d\n", g.DeviceID, blofeldChannel+1)
+	}
+
+	if len(args) > 0 {
+		switch args[0] {
 		case "play":
 			playTestNotes(blo, blofeldChannel)
 			return
 		case "single":
-			singleTest(inPortIdx, portIdx, blo, blofeldChannel)
+			singleTest(portIdx, blo, blofeldChannel)
 			return
 		case "get":
-			getPatch(inPortIdx, portIdx, blo, blofeldChannel)
+			getPatch(portIdx, blo, blofeldChannel, args[1:])
 			return
 		case "set":
-			setPatch(inPortIdx, portIdx, blo, blofeldChannel, blofeldDeviceID)
+			setPatch(portIdx, blo, blofeldChannel, blo.DeviceID(), args[1:])
+			return
+		case "globals":
+			getGlobals(blo)
+			return
+		case "backup":
+			backupSounds(blo, args[1:])
+			return
+		case "restore":
+			restoreSounds(blo, args[1:])
+			return
+		case "diff":
+			diffPatches(blo, args[1:])
+			return
+		case "morph":
+			morphPatches(blo, args[1:])
+			return
+		case "randomize":
+			randomizePatch(blo, args[1:])
+			return
+		case "breed":
+			breedPatches(blo, args[1:])
 			return
 
 		case "mcp":
-			runMCP(inPortIdx, portIdx, blo, blofeldChannel)
+			runMCP(portIdx, blo, blofeldChannel)
 			return
 
 		default:
-			log.Fatalf("unknown command %q", os.Args[1])
+			log.Fatalf("unknown command %q", args[0])
 		}
 	}
 	log.Println("exiting: no command specified")
@@ -107,3 +148,36 @@ func bankToByte(bank string) (byte, error) {
 	}
 	return byte(ch - 'A'), nil
 }
+
+// parseSlot parses a slot name such as "A012" or "h128" into bank and program.
+func parseSlot(slot string) (string, int, error) {
+	slot = strings.TrimSpace(slot)
+	if len(slot) < 2 {
+		return "", 0, fmt.Errorf("slot must look like A001, got %q", slot)
+	}
+
+	bank := strings.ToUpper(slot[:1])
+	if _, err := bankToByte(bank); err != nil {
+		return "", 0, err
+	}
+
+	program, err := strconv.Atoi(slot[1:])
+	if err != nil {
+		return "", 0, fmt.Errorf("slot must look like A001, got %q", slot)
+	}
+	if program < 1 || program > 128 {
+		return "", 0, fmt.Errorf("program must be in range 1–128, got %d", program)
+	}
+	return bank, program, nil
+}
+
+func byteToBank(b byte) string {
+	return string(rune('A' + b))
+}
+
+func instrumentToByte(instrument int) (byte, error) {
+	if instrument < 1 || instrument > 16 {
+		return 0, fmt.Errorf("instrument must be in range 1–16, got %d", instrument)
+	}
+	return byte(instrument - 1), nil
+}
@@ -5,15 +5,25 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"strings"
+	"sync"
+	"time"
 
 	_ "embed"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
-	"gitlab.com/gomidi/midi/v2"
 )
 
-func runMCP(inPortIdx int, portIdx int, blo *Blofeld, blofeldChannel uint8) {
+func runMCP(portIdx int, blo *Blofeld, blofeldChannel uint8) {
+
+	// The note tools follow MIDI channel changes made with blofeld_set-globals.
+	var channelMu sync.Mutex
+	noteChannel := func() uint8 {
+		channelMu.Lock()
+		defer channelMu.Unlock()
+		return blofeldChannel
+	}
 
 	s := server.NewMCPServer(
 		"Blofeld MCP",
@@ -31,6 +41,7 @@ func runMCP(inPortIdx int, portIdx int, blo *Blofeld, blofeldChannel uint8) {
 		mcp.WithDescription("Retrieves a patch from the Blofeld synthesizer."),
 		mcp.WithString("bank", mcp.Required(), mcp.Description("The bank of the patch (e.g., A, B, ..., H).")),
 		mcp.WithNumber("program", mcp.Required(), mcp.Description("The program number of the patch (1-128).")),
+		mcp.WithString("format", mcp.Enum("raw", "display"), mcp.Description(patchFormatDescription)),
 	)
 	s.AddTool(getPatchTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		var bank string
@@ -48,12 +59,12 @@ func runMCP(inPortIdx int, portIdx int, blo *Blofeld, blofeldChannel uint8) {
 			return mcp.NewToolResultError(err.Error()), nil
 		}
 
-		patch, _, err := blo.RequestPatchDump(midi.GetInPorts()[inPortIdx], bank, program)
+		patch, _, err := blo.RequestPatchDump(bank, program)
 		if err != nil {
 			return nil, fmt.Errorf("failed to read patch: %v", err)
 		}
 
-		asJson, err := json.MarshalIndent(&patch, "", "  ")
+		asJson, err := marshalPatch(patch, request.GetString("format", "raw"))
 		if err != nil {
 			return nil, fmt.Errorf("failed to marshal patch to JSON: %v", err)
 		}
@@ -65,7 +76,8 @@ func runMCP(inPortIdx int, portIdx int, blo *Blofeld, blofeldChannel uint8) {
 		mcp.WithDescription("Sends a patch to the Blofeld synthesizer."),
 		mcp.WithString("bank", mcp.Required(), mcp.Description("The bank of the patch (e.g., A, B, ..., H).")),
 		mcp.WithNumber("program", mcp.Required(), mcp.Description("The program number of the patch (1-128).")),
-		mcp.WithString("patch-json", mcp.Required(), mcp.Description("The patch data in JSON format. The JSON must conform to the Patch structure.")),
+		mcp.WithString("patch-json", mcp.Required(), mcp.Description(patchJSONDescription)),
+		mcp.WithString("format", mcp.Enum("raw", "display"), mcp.Description(patchFormatDescription)),
 	)
 	s.AddTool(sendPatchTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		var bank string
@@ -92,22 +104,676 @@ func runMCP(inPortIdx int, portIdx int, blo *Blofeld, blofeldChannel uint8) {
 		log.Println("[mcp] Sending patch to Blofeld. Bank:", bank, "Program:", program, "JSON:", patchJson)
 
 		var patch Patch
-		if err := json.Unmarshal([]byte(patchJson), &patch); err != nil {
+		if err := unmarshalPatch([]byte(patchJson), request.GetString("format", "raw"), &patch); err != nil {
 			return nil, fmt.Errorf("failed to unmarshal patch JSON: %v", err)
 		}
+		if err := patch.Validate(); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
 
-		if err := blo.SendPatch(bank, program, &patch, 0x00); err != nil {
+		if err := blo.SendPatch(bank, program, &patch, blo.DeviceID()); err != nil {
 			return nil, fmt.Errorf("failed to send patch: %v", err)
 		}
 
 		return mcp.NewToolResultText("Patch sent successfully."), nil
 	})
 
+	getEditBufferTool := mcp.NewTool("blofeld_get-edit-buffer",
+		mcp.WithDescription("Retrieves the sound currently loaded in the Blofeld edit buffer, or in the edit buffer of a Multi Mode instrument."),
+		mcp.WithNumber("instrument", mcp.Description(instrumentArgDescription)),
+		mcp.WithString("format", mcp.Enum("raw", "display"), mcp.Description(patchFormatDescription)),
+	)
+	s.AddTool(getEditBufferTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		log.Println("[mcp]Handling get edit buffer request.")
+
+		instrument := request.GetInt("instrument", 1)
+		if _, err := instrumentToByte(instrument); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		patch, _, err := blo.RequestInstrument(instrument)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read edit buffer: %v", err)
+		}
+
+		asJson, err := marshalPatch(patch, request.GetString("format", "raw"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal patch to JSON: %v", err)
+		}
+
+		return mcp.NewToolResultText(string(asJson)), nil
+	})
+
+	sendEditBufferTool := mcp.NewTool("blofeld_send-edit-buffer",
+		mcp.WithDescription("Loads a patch into the Blofeld edit buffer (or a Multi Mode instrument's edit buffer) for auditioning. Stored programs are not modified; use blofeld_save-edit-buffer to store it."),
+		mcp.WithString("patch-json", mcp.Required(), mcp.Description(patchJSONDescription)),
+		mcp.WithNumber("instrument", mcp.Description(instrumentArgDescription)),
+		mcp.WithString("format", mcp.Enum("raw", "display"), mcp.Description(patchFormatDescription)),
+	)
+	s.AddTool(sendEditBufferTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		log.Println("[mcp]Handling send edit buffer request.")
+
+		patchJson, err := request.RequireString("patch-json")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		instrument := request.GetInt("instrument", 1)
+		if _, err := instrumentToByte(instrument); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		var patch Patch
+		if err := unmarshalPatch([]byte(patchJson), request.GetString("format", "raw"), &patch); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal patch JSON: %v", err)
+		}
+		if err := patch.Validate(); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		if err := blo.SendInstrument(instrument, &patch); err != nil {
+			return nil, fmt.Errorf("failed to send patch: %v", err)
+		}
+
+		return mcp.NewToolResultText(fmt.Sprintf("Patch loaded into edit buffer of instrument %d.", instrument)), nil
+	})
+
+	saveEditBufferTool := mcp.NewTool("blofeld_save-edit-buffer",
+		mcp.WithDescription("Stores the sound in the Blofeld edit buffer (or a Multi Mode instrument's edit buffer) to a bank/program, overwriting the stored program."),
+		mcp.WithString("bank", mcp.Required(), mcp.Description("The bank of the patch (e.g., A, B, ..., H).")),
+		mcp.WithNumber("program", mcp.Required(), mcp.Description("The program number of the patch (1-128).")),
+		mcp.WithNumber("instrument", mcp.Description(instrumentArgDescription)),
+	)
+	s.AddTool(saveEditBufferTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		log.Println("[mcp]Handling save edit buffer request.")
+
+		bank, err := request.RequireString("bank")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		program, err := request.RequireInt("program")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		instrument := request.GetInt("instrument", 1)
+		if _, err := instrumentToByte(instrument); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		if err := blo.SaveInstrument(instrument, bank, program); err != nil {
+			return nil, fmt.Errorf("failed to save edit buffer: %v", err)
+		}
+
+		return mcp.NewToolResultText(fmt.Sprintf("Edit buffer of instrument %d saved to bank %s program %d.", instrument, bank, program)), nil
+	})
+
+	setParameterTool := mcp.NewTool("blofeld_set-parameter",
+		mcp.WithDescription("Changes a single sound parameter in the edit buffer immediately (SNDP). Stored programs are not modified."),
+		mcp.WithNumber("index", mcp.Required(), mcp.Description("The SDATA parameter index (0-382), see the SysEx description section 3.1.")),
+		mcp.WithNumber("value", mcp.Required(), mcp.Description("The new raw parameter value (0-127).")),
+		mcp.WithNumber("instrument", mcp.Description(instrumentArgDescription)),
+	)
+	s.AddTool(setParameterTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		log.Println("[mcp]Handling set parameter request.")
+
+		index, err := request.RequireInt("index")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		value, err := request.RequireInt("value")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if value < 0 || value > 127 {
+			return mcp.NewToolResultError(fmt.Sprintf("value must be in range 0-127, got %d", value)), nil
+		}
+
+		instrument := request.GetInt("instrument", 1)
+		if _, err := instrumentToByte(instrument); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		if err := blo.SetInstrumentParameter(instrument, index, byte(value)); err != nil {
+			return nil, fmt.Errorf("failed to set parameter: %v", err)
+		}
+
+		return mcp.NewToolResultText(fmt.Sprintf("Parameter %d of instrument %d set to %d.", index, instrument, value)), nil
+	})
+
+	renamePatchTool := mcp.NewTool("blofeld_rename-patch",
+		mcp.WithDescription("Renames the sound in the edit buffer in place (SNDP), optionally changing its category, without resending the whole sound. Stored programs are not modified; use blofeld_save-edit-buffer to store it."),
+		mcp.WithString("name", mcp.Required(), mcp.Description("The new name. Up to 16 ASCII characters; longer names are truncated and other characters are replaced by the closest ASCII character.")),
+		mcp.WithString("category", mcp.Enum(categoryNames...), mcp.Description("The new category. Unchanged if omitted.")),
+		mcp.WithNumber("instrument", mcp.Description(instrumentArgDescription)),
+	)
+	s.AddTool(renamePatchTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		log.Println("[mcp]Handling rename patch request.")
+
+		name, err := request.RequireString("name")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		var category *Category
+		if categoryName := request.GetString("category", ""); categoryName != "" {
+			c, err := parseEnum(categoryName, categoryNames, "category")
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			category = (*Category)(&c)
+		}
+
+		instrument := request.GetInt("instrument", 1)
+		if _, err := instrumentToByte(instrument); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		if err := blo.RenameInstrument(instrument, name, category); err != nil {
+			return nil, fmt.Errorf("failed to rename patch: %v", err)
+		}
+
+		return mcp.NewToolResultText(fmt.Sprintf("Sound in instrument %d renamed to %q.", instrument, NormalizeName(name))), nil
+	})
+
+	diffPatchesTool := mcp.NewTool("blofeld_diff-patches",
+		mcp.WithDescription("Lists the fields that differ between two patches, with old and new values in display units (signed amounts, semitones, percent, feet)."),
+		mcp.WithString("from", mcp.Required(), mcp.Description(patchSourceDescription)),
+		mcp.WithString("to", mcp.Required(), mcp.Description(patchSourceDescription)),
+		mcp.WithString("format", mcp.Enum("raw", "display"), mcp.Description("The representation of patch JSON given in from/to. \"raw\" (default) or \"display\".")),
+	)
+	s.AddTool(diffPatchesTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		log.Println("[mcp]Handling diff patches request.")
+
+		from, err := request.RequireString("from")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		to, err := request.RequireString("to")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		format := request.GetString("format", "raw")
+
+		a, err := blo.loadPatch(from, format)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("from: %v", err)), nil
+		}
+		b, err := blo.loadPatch(to, format)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("to: %v", err)), nil
+		}
+
+		changes, err := DiffPatches(a, b)
+		if err != nil {
+			return nil, fmt.Errorf("failed to diff patches: %v", err)
+		}
+		if len(changes) == 0 {
+			return mcp.NewToolResultText("The patches are identical."), nil
+		}
+
+		asJson, err := json.MarshalIndent(changes, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal diff to JSON: %v", err)
+		}
+
+		return mcp.NewToolResultText(string(asJson)), nil
+	})
+
+	updatePatchTool := mcp.NewTool("blofeld_update-patch",
+		mcp.WithDescription("Changes some fields of a stored sound or an edit buffer without resending the whole patch JSON. Reads the current sound, applies the changes, validates the result and writes it back. Edit buffers receive only the changed parameters (SNDP); a stored slot is rewritten as a whole."),
+		mcp.WithString("target", mcp.Required(), mcp.Description("The sound to change: a slot like A001, edit for the edit buffer of instrument 1, or edit:N for instrument N (1-16).")),
+		mcp.WithString("changes", mcp.Required(), mcp.Description("Either a partial patch object with only the fields to change, e.g. {\"filters\": [{\"cutoff\": 40}]} (array elements are merged in order, use null to skip an element), or an RFC 6902 JSON Patch array of replace, add and test operations, e.g. [{\"op\": \"replace\", \"path\": \"/filters/0/cutoff\", \"value\": 40}]. "+patchJSONDescription)),
+		mcp.WithString("format", mcp.Enum("raw", "display"), mcp.Description("The units of the values in changes. \"raw\" (default) or \"display\".")),
+	)
+	s.AddTool(updatePatchTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		log.Println("[mcp]Handling update patch request.")
+
+		target, err := request.RequireString("target")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		l, err := parsePatchLocation(target)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		// Clients may pass the changes as JSON text or as a JSON value.
+		var changes []byte
+		switch c := request.GetArguments()["changes"].(type) {
+		case nil:
+			return mcp.NewToolResultError("changes is required"), nil
+		case string:
+			changes = []byte(c)
+		default:
+			if changes, err = json.Marshal(c); err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("invalid changes: %v", err)), nil
+			}
+		}
+
+		old, err := blo.requestLocation(l)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", l, err)
+		}
+
+		updated, err := ApplyPatchUpdate(old, changes, request.GetString("format", "raw"))
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if err := updated.Validate(); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		diff, err := DiffPatches(old, updated)
+		if err != nil {
+			return nil, fmt.Errorf("failed to diff patches: %v", err)
+		}
+		if len(diff) == 0 {
+			return mcp.NewToolResultText(fmt.Sprintf("Nothing to change in %s.", l)), nil
+		}
+
+		sent, err := blo.WriteUpdate(l, old, updated)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update %s: %v", l, err)
+		}
+
+		asJson, err := json.MarshalIndent(diff, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal diff to JSON: %v", err)
+		}
+
+		return mcp.NewToolResultText(fmt.Sprintf("Updated %s with %d messages. Changes:\n%s", l, sent, asJson)), nil
+	})
+
+	morphPatchesTool := mcp.NewTool("blofeld_morph-patches",
+		mcp.WithDescription("Morphs between two patches: continuous parameters are interpolated, discrete ones (shapes, types, sources, modes) switch halfway. Returns the patch at position t, or with sweep_to sweeps an edit buffer from t to sweep_to over time, sending only the changed parameters (SNDP)."),
+		mcp.WithString("from", mcp.Required(), mcp.Description(patchSourceDescription)),
+		mcp.WithString("to", mcp.Required(), mcp.Description(patchSourceDescription)),
+		mcp.WithNumber("t", mcp.Description("The morph position, 0 (from) to 1 (to). Default 0.5; the start of a sweep.")),
+		mcp.WithNumber("sweep_to", mcp.Description("End position of a sweep (0-1). If omitted, nothing is sent and the morphed patch is returned.")),
+		mcp.WithNumber("duration_ms", mcp.Description("Length of a sweep in milliseconds. Default 2000.")),
+		mcp.WithNumber("steps", mcp.Description("Number of steps of a sweep. Default 32.")),
+		mcp.WithNumber("instrument", mcp.Description(instrumentArgDescription)),
+		mcp.WithString("format", mcp.Enum("raw", "display"), mcp.Description(patchFormatDescription)),
+	)
+	s.AddTool(morphPatchesTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		log.Println("[mcp]Handling morph patches request.")
+
+		from, err := request.RequireString("from")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		to, err := request.RequireString("to")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		format := request.GetString("format", "raw")
+
+		a, err := blo.loadPatch(from, format)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("from: %v", err)), nil
+		}
+		b, err := blo.loadPatch(to, format)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("to: %v", err)), nil
+		}
+
+		t := request.GetFloat("t", 0.5)
+		if _, ok := request.GetArguments()["sweep_to"]; !ok {
+			p, err := MorphPatches(a, b, t)
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			asJson, err := marshalPatch(p, format)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal patch to JSON: %v", err)
+			}
+			return mcp.NewToolResultText(string(asJson)), nil
+		}
+
+		opts := SweepOptions{
+			Instrument: request.GetInt("instrument", 1),
+			From:       t,
+			To:         request.GetFloat("sweep_to", 1),
+			Steps:      request.GetInt("steps", 32),
+			Duration:   time.Duration(request.GetInt("duration_ms", 2000)) * time.Millisecond,
+		}
+		if _, err := instrumentToByte(opts.Instrument); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		for _, pos := range []float64{opts.From, opts.To} {
+			if _, err := MorphPatches(a, b, pos); err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+		}
+		if opts.Steps < 1 {
+			return mcp.NewToolResultError("steps must be at least 1"), nil
+		}
+		if err := a.Validate(); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("from: %v", err)), nil
+		}
+		if err := b.Validate(); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("to: %v", err)), nil
+		}
+
+		sent, err := blo.SweepMorph(a, b, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sweep: %v", err)
+		}
+
+		return mcp.NewToolResultText(fmt.Sprintf("Swept instrument %d from %v to %v in %v with %d messages.", opts.Instrument, opts.From, opts.To, opts.Duration, sent)), nil
+	})
+
+	randomizeTool := mcp.NewTool("blofeld_randomize",
+		mcp.WithDescription("Randomizes sections of a sound within the valid parameter ranges and loads the result into an edit buffer for auditioning. Stored programs are not modified. Returns the new patch."),
+		mcp.WithString("source", mcp.Description(patchSourceDescription+" Default: the edit buffer of the instrument.")),
+		mcp.WithArray("sections", mcp.WithStringItems(mcp.Enum(randomSectionNames()...)), mcp.Description("The sections to randomize. Default: all.")),
+		mcp.WithNumber("amount", mcp.Description("How far to move from the source, 0 to 1. Continuous parameters move by up to this fraction of their range, discrete ones (shapes, types, sources, modes) change with this probability. 1 draws everything afresh. Default 0.3.")),
+		mcp.WithNumber("seed", mcp.Description("Seed of the random generator, reported in the result. The same source, options and seed give the same sound. Default: a new seed.")),
+		mcp.WithArray("locks", mcp.WithStringItems(), mcp.Description("JSON paths to keep unchanged, e.g. \"filters[0].cutoff\", \"envelopes[3]\" or \"oscillators[].octave\" for every oscillator.")),
+		mcp.WithNumber("instrument", mcp.Description(instrumentArgDescription)),
+		mcp.WithString("format", mcp.Enum("raw", "display"), mcp.Description(patchFormatDescription)),
+	)
+	s.AddTool(randomizeTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		log.Println("[mcp]Handling randomize request.")
+
+		instrument := request.GetInt("instrument", 1)
+		if _, err := instrumentToByte(instrument); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		format := request.GetString("format", "raw")
+		source := request.GetString("source", fmt.Sprintf("edit:%d", instrument))
+
+		patch, err := blo.loadPatch(source, format)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("source: %v", err)), nil
+		}
+
+		opts := RandomizeOptions{
+			Sections: request.GetStringSlice("sections", nil),
+			Amount:   request.GetFloat("amount", 0.3),
+			Seed:     int64(request.GetInt("seed", int(newSeed()))),
+			Locks:    request.GetStringSlice("locks", nil),
+		}
+		if err := patch.Randomize(opts); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if err := patch.Validate(); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		if err := blo.SendInstrument(instrument, patch); err != nil {
+			return nil, fmt.Errorf("failed to send patch: %v", err)
+		}
+
+		asJson, err := marshalPatch(patch, format)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal patch to JSON: %v", err)
+		}
+
+		return mcp.NewToolResultText(fmt.Sprintf("Randomized sound (seed %d) loaded into edit buffer of instrument %d:\n%s", opts.Seed, instrument, asJson)), nil
+	})
+
+	// The breeding session lives as long as the MCP server.
+	var populationMu sync.Mutex
+	var population *Population
+
+	breedTool := mcp.NewTool("blofeld_breed",
+		mcp.WithDescription("Breeds sounds: children take each section (oscillators, filters, envelopes, modulation, ...) from a random parent and are then mutated. \"start\" breeds a first generation from parents; audition children one by one in the edit buffer, mark them keep or discard, and \"next\" breeds a new generation from the kept ones."),
+		mcp.WithString("action", mcp.Required(), mcp.Enum("start", "audition", "keep", "discard", "next", "show", "status"), mcp.Description("start: breed from parents. audition: load a child into the edit buffer. keep/discard: give feedback on a child. next: breed from the kept children. show: return a child's patch JSON. status: list the current generation.")),
+		mcp.WithArray("parents", mcp.WithStringItems(), mcp.Description("For start: the parent sounds, each a slot (A001), edit, edit:N or patch JSON.")),
+		mcp.WithNumber("children", mcp.Description("For start: children per generation. Default 6.")),
+		mcp.WithNumber("mutation", mcp.Description("For start: how much each child is mutated, 0 to 1. Default 0.1.")),
+		mcp.WithNumber("seed", mcp.Description("For start and next: seed of the generation, reported in the result. The same parents, options and seed give the same children. Default: a new seed.")),
+		mcp.WithNumber("child", mcp.Description("For audition, keep, discard and show: the child number, starting at 1.")),
+		mcp.WithNumber("instrument", mcp.Description(instrumentArgDescription)),
+		mcp.WithString("format", mcp.Enum("raw", "display"), mcp.Description(patchFormatDescription)),
+	)
+	s.AddTool(breedTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		log.Println("[mcp]Handling breed request.")
+
+		action, err := request.RequireString("action")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		format := request.GetString("format", "raw")
+		seed := int64(request.GetInt("seed", int(newSeed())))
+
+		populationMu.Lock()
+		defer populationMu.Unlock()
+
+		if action == "start" {
+			sources := request.GetStringSlice("parents", nil)
+			if len(sources) == 0 {
+				return mcp.NewToolResultError("start needs parents"), nil
+			}
+			var parents []*Patch
+			for _, source := range sources {
+				p, err := blo.loadPatch(source, format)
+				if err != nil {
+					return mcp.NewToolResultError(fmt.Sprintf("parent %s: %v", source, err)), nil
+				}
+				parents = append(parents, p)
+			}
+
+			opts := BreedOptions{
+				Children: request.GetInt("children", 6),
+				Mutation: request.GetFloat("mutation", 0.1),
+				Seed:     seed,
+			}
+			pop, err := NewPopulation(parents, opts)
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			population = pop
+			return mcp.NewToolResultText(population.String()), nil
+		}
+
+		if population == nil {
+			return mcp.NewToolResultError("no breeding session, use action start first"), nil
+		}
+
+		switch action {
+		case "status":
+			return mcp.NewToolResultText(population.String()), nil
+		case "next":
+			if err := population.Next(seed); err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			return mcp.NewToolResultText(population.String()), nil
+		}
+
+		n, err := request.RequireInt("child")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		child, err := population.Child(n)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		switch action {
+		case "audition":
+			instrument := request.GetInt("instrument", 1)
+			if _, err := instrumentToByte(instrument); err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			// Parents loaded from JSON may be out of range, and crossover
+			// passes their values on.
+			if err := child.Validate(); err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("child %d: %v", n, err)), nil
+			}
+			if err := blo.SendInstrument(instrument, child); err != nil {
+				return nil, fmt.Errorf("failed to send patch: %v", err)
+			}
+			return mcp.NewToolResultText(fmt.Sprintf("Child %d loaded into edit buffer of instrument %d.", n, instrument)), nil
+		case "keep", "discard":
+			verdict := VerdictKeep
+			if action == "discard" {
+				verdict = VerdictDiscard
+			}
+			_ = population.Judge(n, verdict) // n was checked above
+			return mcp.NewToolResultText(population.String()), nil
+		case "show":
+			asJson, err := marshalPatch(child, format)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal patch to JSON: %v", err)
+			}
+			return mcp.NewToolResultText(string(asJson)), nil
+		}
+		return mcp.NewToolResultError(fmt.Sprintf("unknown action %q", action)), nil
+	})
+
+	describeEffectsTool := mcp.NewTool("blofeld_describe-effects",
+		mcp.WithDescription("Returns the effect types and the named, range-checked parameters of each type (SysEx description section 5), as used in the \"parameters\" object of a patch's effects and by blofeld_set-effect."),
+	)
+	s.AddTool(describeEffectsTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		log.Println("[mcp]Handling describe effects request.")
+
+		asJson, err := json.MarshalIndent(describeEffects(), "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal effect schemas to JSON: %v", err)
+		}
+
+		return mcp.NewToolResultText(string(asJson)), nil
+	})
+
+	setEffectTool := mcp.NewTool("blofeld_set-effect",
+		mcp.WithDescription("Sets the type, mix and named parameters of an effect unit in the edit buffer immediately (SNDP). Stored programs are not modified. Parameters not given keep their current values; when one is out of range for the new type, the change is rejected and the parameter must be given."),
+		mcp.WithNumber("effect", mcp.Required(), mcp.Description("The effect unit, 1 or 2. Delay, Clk.Delay and Reverb are only available on 2.")),
+		mcp.WithString("type", mcp.Required(), mcp.Enum(effectTypeNames...), mcp.Description("The effect type.")),
+		mcp.WithNumber("mix", mcp.Description("The effect mix (0-127). Unchanged if omitted.")),
+		mcp.WithObject("parameters", mcp.Description(effectParamsDescription())),
+		mcp.WithNumber("instrument", mcp.Description(instrumentArgDescription)),
+	)
+	s.AddTool(setEffectTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		log.Println("[mcp]Handling set effect request.")
+
+		effect, err := request.RequireInt("effect")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		instrument := request.GetInt("instrument", 1)
+		if _, err := instrumentToByte(instrument); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		typeName, err := request.RequireString("type")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		typ, err := parseEnum(typeName, effectTypeNames, "effect type")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		params := make(map[string]json.RawMessage)
+		if obj, ok := request.GetArguments()["parameters"].(map[string]any); ok {
+			for name, v := range obj {
+				raw, err := json.Marshal(v)
+				if err != nil {
+					return mcp.NewToolResultError(err.Error()), nil
+				}
+				params[name] = raw
+			}
+		}
+
+		// Slots the new type inherits from the current one must be in range.
+		current, _, err := blo.RequestInstrument(instrument)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read instrument %d: %v", instrument, err)
+		}
+
+		updates, err := effectUpdates(current, effect-1, EffectType(typ), params)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		if mix := request.GetInt("mix", -1); mix >= 0 {
+			if mix > 127 {
+				return mcp.NewToolResultError(fmt.Sprintf("mix must be in range 0-127, got %d", mix)), nil
+			}
+			updates = append(updates, paramUpdate{effectFieldMapping[effect-1].mix, byte(mix)})
+		}
+
+		for _, u := range updates {
+			if err := blo.SetInstrumentParameter(instrument, u.Index, u.Value); err != nil {
+				return nil, fmt.Errorf("failed to set effect: %v", err)
+			}
+		}
+
+		return mcp.NewToolResultText(fmt.Sprintf("Effect %d of instrument %d set to %s (%d parameter changes sent).", effect, instrument, EffectType(typ), len(updates))), nil
+	})
+
+	getGlobalsTool := mcp.NewTool("blofeld_get-globals",
+		mcp.WithDescription("Retrieves the global settings (multi mode, MIDI channel, device ID, master tune, transpose, velocity curve, Control W-Z, volume, ...) from the Blofeld synthesizer."),
+	)
+	s.AddTool(getGlobalsTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		log.Println("[mcp]Handling get globals request.")
+
+		g, _, err := blo.RequestGlobalDump()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read global data: %v", err)
+		}
+
+		asJson, err := json.MarshalIndent(g, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal global data to JSON: %v", err)
+		}
+
+		return mcp.NewToolResultText(string(asJson)), nil
+	})
+
+	setGlobalsTool := mcp.NewTool("blofeld_set-globals",
+		mcp.WithDescription("Writes global settings to the Blofeld synthesizer. The current settings are read first, so only the fields given in the JSON are changed and reserved bytes are kept. Values are checked against the ranges of the SysEx spec before sending. Later requests use the new device_id, and the note tools play on the new midi_channel (omni keeps the current one)."),
+		mcp.WithString("globals-json", mcp.Required(), mcp.Description("The settings to change in JSON format, using the field names returned by blofeld_get-globals.")),
+	)
+	s.AddTool(setGlobalsTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		log.Println("[mcp]Handling set globals request.")
+
+		globalsJson, err := request.RequireString("globals-json")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		g, _, err := blo.RequestGlobalDump()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read global data: %v", err)
+		}
+
+		if err := json.Unmarshal([]byte(globalsJson), g); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("failed to unmarshal globals JSON: %v", err)), nil
+		}
+		if err := g.Validate(); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		if err := blo.SendGlobals(g); err != nil {
+			return nil, fmt.Errorf("failed to send global data: %v", err)
+		}
+		// The Blofeld answers to its new device ID from now on.
+		blo.SetDeviceID(g.DeviceID)
+		if ch, ok := g.Channel(); ok {
+			channelMu.Lock()
+			blofeldChannel = ch
+			channelMu.Unlock()
+		}
+
+		return mcp.NewToolResultText("Global settings sent successfully."), nil
+	})
+
 	playNotesTool := mcp.NewTool("blofeld_play-test-notes",
 		mcp.WithDescription("Plays test notes on the Blofeld synthesizer."),
 	)
 	s.AddTool(playNotesTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		if err := playTestNotes(blo, blofeldChannel); err != nil {
+		if err := playTestNotes(blo, noteChannel()); err != nil {
 			return nil, fmt.Errorf("failed to play test notes: %v", err)
 		}
 		return mcp.NewToolResultText("Test notes played successfully."), nil
@@ -117,7 +783,7 @@ func runMCP(inPortIdx int, portIdx int, blo *Blofeld, blofeldChannel uint8) {
 		mcp.WithDescription("Plays a C minor 7 chord on the Blofeld."),
 	)
 	s.AddTool(minor7Tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		if err := playMinor7Chord(blo, blofeldChannel); err != nil {
+		if err := playMinor7Chord(blo, noteChannel()); err != nil {
 			return nil, fmt.Errorf("failed to play minor 7 chord: %v", err)
 		}
 		return mcp.NewToolResultText("C minor 7 chord played successfully."), nil
@@ -133,7 +799,7 @@ func runMCP(inPortIdx int, portIdx int, blo *Blofeld, blofeldChannel uint8) {
 			return mcp.NewToolResultError(err.Error()), nil
 		}
 
-		if err := playNotesFromText(blo, blofeldChannel, notesText); err != nil {
+		if err := playNotesFromText(blo, noteChannel(), notesText); err != nil {
 			return nil, fmt.Errorf("failed to play notes: %v", err)
 		}
 		return mcp.NewToolResultText(fmt.Sprintf("Played notes: %s", notesText)), nil
@@ -147,6 +813,32 @@ func runMCP(inPortIdx int, portIdx int, blo *Blofeld, blofeldChannel uint8) {
 
 }
 
+const patchJSONDescription = "The patch data in JSON format. The JSON must conform to the Patch structure. Shapes, filter types, drive curves, modulation sources and destinations, modifier operators and the category may be given by name (as returned by blofeld_get-patch) or by their raw number. Keep the \"raw\" field returned by blofeld_get-patch so that reserved and undocumented bytes are preserved; only the named fields are applied on top of it."
+
+const patchFormatDescription = "The patch JSON representation. \"raw\" (default) uses the SDATA bytes. \"display\" uses real units: signed amounts (-64..+63), semitones, keytrack percent and octaves in feet (e.g. \"8'\")."
+
+// effectParamsDescription lists the named parameters per effect type for the
+// blofeld_set-effect schema.
+func effectParamsDescription() string {
+	var sb strings.Builder
+	sb.WriteString("Named effect parameters as raw values; see blofeld_describe-effects for ranges.")
+	for _, schema := range describeEffects() {
+		if len(schema.Parameters) == 0 {
+			continue
+		}
+		names := make([]string, len(schema.Parameters))
+		for i, prm := range schema.Parameters {
+			names[i] = prm.Name
+		}
+		fmt.Fprintf(&sb, " %s: %s.", schema.Type, strings.Join(names, ", "))
+	}
+	return sb.String()
+}
+
+const patchSourceDescription = "A patch: a slot such as \"A001\", \"edit\" for the edit buffer (\"edit:3\" for Multi Mode instrument 3), or patch JSON."
+
+const instrumentArgDescription = "The Multi Mode instrument (1-16) whose edit buffer to use. Defaults to 1, which is also the Sound Mode Edit Buffer."
+
 //go:embed waldorf_blofeld_sysex_documentation_v.1.04.txt
 var sysexDoc string
 
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// morphThreshold is the position at which discrete fields switch from the
+// first patch to the second.
+const morphThreshold = 0.5
+
+// discreteFields lists the plain byte fields of the Patch JSON that select
+// a mode or step rather than set an amount, so they are switched instead of
+// interpolated. Array elements are addressed with "[]", as in displayUnits.
+// Enums, flags, the name and the raw SDATA are always switched.
+var discreteFields = map[string]bool{
+	"oscillators[].octave":   true,
+	"oscillators[].limit_wt": true,
+	"osc2_sync":              true,
+	"filter_routing":         true,
+	"glide":                  true,
+	"glide_mode":             true,
+	"unison.voices":          true,
+
+	"lfos[].sync":    true,
+	"lfos[].clocked": true,
+
+	"arp_mode":                    true,
+	"arp_pattern":                 true,
+	"arp_clock":                   true,
+	"arp_length":                  true,
+	"arp_range":                   true,
+	"arp_direction":               true,
+	"arp_sort":                    true,
+	"arp_velocity_mode":           true,
+	"arp_pattern_reset":           true,
+	"arp_pattern_length":          true,
+	"arp_pattern_steps[].accent":  true,
+	"arp_pattern_timing[].length": true,
+	"arp_pattern_timing[].timing": true,
+
+	"subcategory": true,
+}
+
+var byteType = reflect.TypeOf(byte(0))
+
+// MorphPatches returns the patch at position t between a (t = 0) and b
+// (t = 1). Continuous parameters are interpolated linearly and rounded;
+// discrete ones (see discreteFields) are taken from a below morphThreshold
+// and from b from there on. Effects of different types switch as a whole.
+func MorphPatches(a, b *Patch, t float64) (*Patch, error) {
+	if t < 0 || t > 1 || math.IsNaN(t) {
+		return nil, fmt.Errorf("morph position %v is outside 0..1", t)
+	}
+
+	out := &Patch{}
+	morphValue("", reflect.ValueOf(a).Elem(), reflect.ValueOf(b).Elem(), reflect.ValueOf(out).Elem(), t)
+
+	for i := range out.Effects {
+		ea, eb := a.Effects[i], b.Effects[i]
+		if ea.Type != eb.Type {
+			out.Effects[i] = pickMorph(ea, eb, t)
+			continue
+		}
+		// Named values like a drive curve or polarity are discrete too.
+		for _, prm := range effectParams[ea.Type] {
+			if prm.Values == nil {
+				continue
+			}
+			out.Effects[i].Params[prm.Slot] = pickMorph(ea.Params[prm.Slot], eb.Params[prm.Slot], t)
+		}
+	}
+	if out.Raw != nil {
+		out.Raw = append([]byte(nil), out.Raw...)
+	}
+	return out, nil
+}
+
+func pickMorph[T any](a, b T, t float64) T {
+	if t < morphThreshold {
+		return a
+	}
+	return b
+}
+
+func morphValue(path string, a, b, out reflect.Value, t float64) {
+	switch out.Kind() {
+	case reflect.Struct:
+		typ := out.Type()
+		for i := 0; i < typ.NumField(); i++ {
+			name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+			if path != "" {
+				name = path + "." + name
+			}
+			morphValue(name, a.Field(i), b.Field(i), out.Field(i), t)
+		}
+	case reflect.Array:
+		for i := 0; i < out.Len(); i++ {
+			morphValue(path+"[]", a.Index(i), b.Index(i), out.Index(i), t)
+		}
+	case reflect.Uint8:
+		if out.Type() == byteType && !discreteFields[path] {
+			va, vb := float64(a.Uint()), float64(b.Uint())
+			out.SetUint(uint64(math.Round(va + (vb-va)*t)))
+			return
+		}
+		out.Set(pickMorph(a, b, t))
+	default:
+		out.Set(pickMorph(a, b, t))
+	}
+}
+
+// SweepOptions controls a morph sweep on an edit buffer.
+type SweepOptions struct {
+	Instrument int           // 1-16
+	From, To   float64       // morph positions, see MorphPatches
+	Steps      int           // number of steps after the initial sound
+	Duration   time.Duration // total time of the sweep
+}
+
+// SweepMorph loads the morph at opts.From into the edit buffer and moves to
+// opts.To in opts.Steps steps, sending SNDP messages only for the parameters
+// that change in each step. Both patches must pass Validate. It returns the
+// number of messages sent.
+func (b *Blofeld) SweepMorph(from, to *Patch, opts SweepOptions) (int, error) {
+	if opts.Steps < 1 {
+		return 0, fmt.Errorf("a sweep needs at least one step, got %d", opts.Steps)
+	}
+	if err := from.Validate(); err != nil {
+		return 0, fmt.Errorf("from: %w", err)
+	}
+	if err := to.Validate(); err != nil {
+		return 0, fmt.Errorf("to: %w", err)
+	}
+
+	prev, err := MorphPatches(from, to, opts.From)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := MorphPatches(from, to, opts.To); err != nil {
+		return 0, err
+	}
+	if err := b.SendInstrument(opts.Instrument, prev); err != nil {
+		return 0, err
+	}
+
+	sent := 1
+	for i := 1; i <= opts.Steps; i++ {
+		time.Sleep(opts.Duration / time.Duration(opts.Steps))
+
+		t := opts.From + (opts.To-opts.From)*float64(i)/float64(opts.Steps)
+		p, err := MorphPatches(from, to, t)
+		if err != nil {
+			return sent, err
+		}
+		updates, err := changedParameters(prev, p)
+		if err != nil {
+			return sent, err
+		}
+		for _, u := range updates {
+			if err := b.SetInstrumentParameter(opts.Instrument, u.Index, u.Value); err != nil {
+				return sent, err
+			}
+			sent++
+		}
+		prev = p
+	}
+	return sent, nil
+}
+
+func morphPatches(blo *Blofeld, args []string) {
+	fs := flag.NewFlagSet("morph", flag.ExitOnError)
+	t := fs.Float64("t", 0.5, "morph position, 0 (first patch) to 1 (second patch)")
+	format := fs.String("format", "raw", "format of JSON patch files and of the output: raw or display")
+	sweep := fs.Duration("sweep", 0, "sweep the edit buffer from -t to -to over this time instead of printing the patch")
+	to := fs.Float64("to", 1, "end position of a sweep")
+	steps := fs.Int("steps", 32, "number of steps of a sweep")
+	instrument := fs.Int("instrument", 1, "instrument whose edit buffer is swept (1-16)")
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		log.Fatalf("usage: morph [-t 0.5] [-format raw|display] [-sweep 5s -to 1 -steps 32 -instrument 1] <a> <b> (each a file, a slot like A001, edit or edit:N)")
+	}
+
+	var patches [2]*Patch
+	for i, source := range fs.Args() {
+		var err error
+		if patches[i], err = loadPatchArg(blo, source, *format); err != nil {
+			log.Fatalf("failed to load %s: %v", source, err)
+		}
+	}
+
+	if *sweep > 0 {
+		opts := SweepOptions{Instrument: *instrument, From: *t, To: *to, Steps: *steps, Duration: *sweep}
+		sent, err := blo.SweepMorph(patches[0], patches[1], opts)
+		if err != nil {
+			log.Fatalf("failed to sweep: %v", err)
+		}
+		log.Printf("Swept from %v to %v with %d messages\n", *t, *to, sent)
+		return
+	}
+
+	p, err := MorphPatches(patches[0], patches[1], *t)
+	if err != nil {
+		log.Fatalf("failed to morph: %v", err)
+	}
+	asJson, err := marshalPatch(p, *format)
+	if err != nil {
+		log.Fatalf("failed to marshal patch: %v", err)
+	}
+	fmt.Println(string(asJson))
+}
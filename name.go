@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// nameRunes maps common characters outside the Blofeld character set
+// (ASCII 32..127) to the closest one that is inside it.
+var nameRunes = map[rune]rune{
+	'‘': '\'', '’': '\'', '‚': ',', '“': '"', '”': '"', '„': '"',
+	'–': '-', '—': '-', '·': '.', '•': '*', '×': 'x', '÷': '/',
+}
+
+// nameLetters maps accented Latin letters to their base letter.
+var nameLetters = map[string]string{
+	"a": "àáâãäåā", "A": "ÀÁÂÃÄÅĀ", "c": "çćč", "C": "ÇĆČ", "e": "èéêëēė",
+	"E": "ÈÉÊËĒĖ", "i": "ìíîïī", "I": "ÌÍÎÏĪ", "n": "ñń", "N": "ÑŃ",
+	"o": "òóôõöøō", "O": "ÒÓÔÕÖØŌ", "s": "śšß", "S": "ŚŠ", "u": "ùúûüū",
+	"U": "ÙÚÛÜŪ", "y": "ýÿ", "Y": "Ý", "z": "źżž", "Z": "ŹŻŽ",
+}
+
+func nameRune(r rune) rune {
+	switch {
+	case r >= 32 && r <= 127:
+		return r
+	case unicode.IsSpace(r) || unicode.IsControl(r):
+		return ' '
+	}
+	if m, ok := nameRunes[r]; ok {
+		return m
+	}
+	for base, accented := range nameLetters {
+		if strings.ContainsRune(accented, r) {
+			return rune(base[0])
+		}
+	}
+	return '?'
+}
+
+// NormalizeName returns name as the Blofeld stores it: characters outside
+// ASCII 32..127 mapped to the closest match (or '?'), truncated or padded
+// with spaces to 16 characters.
+func NormalizeName(name string) string {
+	var sb strings.Builder
+	for _, r := range name {
+		if sb.Len() == nameLength {
+			break
+		}
+		sb.WriteRune(nameRune(r))
+	}
+	for sb.Len() < nameLength {
+		sb.WriteByte(' ')
+	}
+	return sb.String()
+}
+
+func nameBytes(name string) []byte {
+	return []byte(NormalizeName(name))
+}
+
+// RenameInstrument changes the name, and optionally the category, of the
+// sound in an instrument's edit buffer in place via SNDP, without resending
+// the whole sound.
+func (b *Blofeld) RenameInstrument(instrument int, name string, category *Category) error {
+	if _, err := instrumentToByte(instrument); err != nil {
+		return err
+	}
+	if category != nil && int(*category) >= len(categoryNames) {
+		return fmt.Errorf("unknown category %d", *category)
+	}
+
+	for i, c := range nameBytes(name) {
+		if err := b.SetInstrumentParameter(instrument, nameIdx+i, c); err != nil {
+			return fmt.Errorf("failed to rename sound: %w", err)
+		}
+	}
+
+	if category != nil {
+		if err := b.SetInstrumentParameter(instrument, categoryIdx, byte(*category)); err != nil {
+			return fmt.Errorf("failed to set category: %w", err)
+		}
+	}
+	return nil
+}
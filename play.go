@@ -12,7 +12,7 @@ import (
 	"gitlab.com/gomidi/midi/v2"
 )
 
-func singleTest(inPortIdx int, portIdx int, blo *Blofeld, blofeldChannel uint8) {
+func singleTest(portIdx int, blo *Blofeld, blofeldChannel uint8) {
 
 	if err := playTestNotes(blo, blofeldChannel); err != nil {
 		log.Fatalf("failed to play test notes: %v", err)
@@ -20,15 +20,13 @@ func singleTest(inPortIdx int, portIdx int, blo *Blofeld, blofeldChannel uint8)
 
 	log.Printf("Connected to Blofeld on port index %d (channel %d).\n", portIdx, blofeldChannel+1)
 
-	var Bank = "H"
-	var Program = 128
-
-	p, devID, err := blo.RequestPatchDump(midi.GetInPorts()[inPortIdx], Bank, Program)
+	// Work on the edit buffer so the experiment never overwrites a stored program.
+	p, devID, err := blo.RequestEditBuffer()
 	if err != nil {
 		log.Fatalf("failed to read patch: %v", err)
 	}
 	log.Println("Patch name", p.Name)
-	log.Printf("Read patch from Bank %s, Program %d (device 0x%02X): %+v\n", Bank, Program, devID, p)
+	log.Printf("Read patch from edit buffer (device 0x%02X): %+v\n", devID, p)
 
 	asJson, err := json.MarshalIndent(&p, "", "  ")
 	if err != nil {
@@ -42,7 +40,7 @@ func singleTest(inPortIdx int, portIdx int, blo *Blofeld, blofeldChannel uint8)
 
 	log.Printf("Patch as JSON:\n%s\n", asJson)
 
-	if err := blo.SendPatch(Bank, Program, p, devID); err != nil {
+	if err := blo.SendEditBuffer(p); err != nil {
 		log.Fatalf("failed to send patch: %v", err)
 	}
 
@@ -51,7 +49,7 @@ func singleTest(inPortIdx int, portIdx int, blo *Blofeld, blofeldChannel uint8)
 	}
 
 	log.Println("Reading again.")
-	p2, devID, err := blo.RequestPatchDump(midi.GetInPorts()[inPortIdx], Bank, Program)
+	p2, _, err := blo.RequestEditBuffer()
 	if err != nil {
 		log.Fatalf("failed to read patch: %v", err)
 	}
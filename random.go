@@ -0,0 +1,290 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// randomSections groups the top-level fields of the Patch JSON into the
+// sections that can be randomized. Fields not listed here (name, category,
+// master tune, the raw SDATA and amp_pan/amp_drive, which have no SDATA
+// index of their own) are never randomized.
+var randomSections = map[string][]string{
+	"oscillators": {"oscillators", "osc2_sync", "osc_pitch_source", "osc_pitch_amount"},
+	"mixer": {"mix_osc1", "mix_osc1_balance", "mix_osc2", "mix_osc2_balance", "mix_osc3", "mix_osc3_balance",
+		"mix_noise", "mix_noise_balance", "mix_noise_color", "mix_ring", "mix_ring_balance"},
+	"filters":    {"filters", "filter_routing"},
+	"amplifier":  {"amp_volume", "amp_velocity", "amp_mod_source", "amp_mod_amount"},
+	"envelopes":  {"envelopes"},
+	"lfos":       {"lfos"},
+	"modulation": {"mod_matrix", "modifiers"},
+	"effects":    {"effects"},
+	"common":     {"glide", "glide_mode", "glide_rate", "unison", "unison_detune"},
+	"arpeggiator": {"arp_mode", "arp_pattern", "arp_clock", "arp_length", "arp_range", "arp_direction", "arp_sort",
+		"arp_velocity_mode", "arp_timing_factor", "arp_pattern_reset", "arp_pattern_length", "arp_tempo",
+		"arp_pattern_steps", "arp_pattern_timing"},
+}
+
+func randomSectionNames() []string {
+	names := make([]string, 0, len(randomSections))
+	for name := range randomSections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// newSeed picks a seed for generative operations called without one. It is
+// kept below 2^31 so it survives JSON numbers and is easy to pass on.
+func newSeed() int64 {
+	return time.Now().UnixNano() & (1<<31 - 1)
+}
+
+// seedFlag returns seed if the -seed flag of fs was given, including 0, and
+// a new seed otherwise.
+func seedFlag(fs *flag.FlagSet, seed int64) int64 {
+	given := false
+	fs.Visit(func(f *flag.Flag) {
+		given = given || f.Name == "seed"
+	})
+	if !given {
+		return newSeed()
+	}
+	return seed
+}
+
+// RandomizeOptions controls Randomize.
+type RandomizeOptions struct {
+	// Sections to randomize, keys of randomSections. Empty means all.
+	Sections []string
+	// Amount from 0 to 1. Continuous parameters move by up to Amount times
+	// their range; discrete ones (enums, flags, modes) are redrawn with
+	// probability Amount. 1 draws every parameter afresh.
+	Amount float64
+	Seed   int64
+	// Locks are JSON paths that stay unchanged, either exact
+	// ("filters[0].cutoff"), a prefix ("envelopes[3]", "effects") or with
+	// "[]" for every element ("oscillators[].octave").
+	Locks []string
+}
+
+type randomizer struct {
+	rng    *rand.Rand
+	amount float64
+	locks  []string
+	ranges map[string]fieldRange
+}
+
+// Randomize changes the selected sections of p at random within the ranges
+// that Validate accepts. The same options and starting patch always give
+// the same result.
+func (p *Patch) Randomize(opts RandomizeOptions) error {
+	if opts.Amount < 0 || opts.Amount > 1 || math.IsNaN(opts.Amount) {
+		return fmt.Errorf("amount %v is outside 0..1", opts.Amount)
+	}
+
+	selected := make(map[string]bool)
+	sections := opts.Sections
+	if len(sections) == 0 {
+		sections = randomSectionNames()
+	}
+	for _, s := range sections {
+		fields, ok := randomSections[strings.ToLower(strings.TrimSpace(s))]
+		if !ok {
+			return fmt.Errorf("unknown section %q (valid: %s)", s, strings.Join(randomSectionNames(), ", "))
+		}
+		for _, f := range fields {
+			selected[f] = true
+		}
+	}
+
+	r := &randomizer{
+		rng:    rand.New(rand.NewSource(opts.Seed)),
+		amount: opts.Amount,
+		locks:  opts.Locks,
+		ranges: p.fieldRanges(),
+	}
+
+	val := reflect.ValueOf(p).Elem()
+	typ := val.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+		if !selected[name] {
+			continue
+		}
+		if name == "effects" {
+			r.effects(p)
+			continue
+		}
+		r.walk(name, val.Field(i))
+	}
+	return nil
+}
+
+func (r *randomizer) walk(path string, v reflect.Value) {
+	if r.locked(path) {
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		typ := v.Type()
+		for i := 0; i < typ.NumField(); i++ {
+			name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+			r.walk(path+"."+name, v.Field(i))
+		}
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			r.walk(fmt.Sprintf("%s[%d]", path, i), v.Index(i))
+		}
+	case reflect.Uint8:
+		rg, ok := r.ranges[path]
+		if !ok {
+			rg = fieldRange{0, 127, 1}
+		}
+		discrete := v.Type() != byteType || discreteFields[pathPattern(path)]
+		v.SetUint(uint64(r.value(byte(v.Uint()), rg, discrete)))
+	case reflect.Bool:
+		if r.rng.Float64() < r.amount {
+			v.SetBool(r.rng.Intn(2) == 1)
+		}
+	}
+}
+
+// effects randomizes the effect types first, then the named parameters of
+// the resulting types; unused parameter slots are left alone.
+func (r *randomizer) effects(p *Patch) {
+	for i := range p.Effects {
+		e := &p.Effects[i]
+		path := fmt.Sprintf("effects[%d]", i)
+		if r.locked(path) {
+			continue
+		}
+
+		maxType := byte(EffectTripleFX)
+		if i == 1 {
+			maxType = byte(EffectReverb)
+		}
+		if !r.locked(path + ".type") {
+			e.Type = EffectType(r.value(byte(e.Type), fieldRange{0, maxType, 1}, true))
+		}
+		if !r.locked(path + ".mix") {
+			e.Mix = r.value(e.Mix, fieldRange{0, 127, 1}, false)
+		}
+
+		for _, prm := range effectParams[e.Type] {
+			if r.locked(fmt.Sprintf("%s.parameters.%s", path, prm.Name)) {
+				continue
+			}
+			e.Params[prm.Slot] = r.value(e.Params[prm.Slot], fieldRange{0, prm.Max, 1}, prm.Values != nil)
+		}
+	}
+}
+
+// value returns a new random value for old within rg.
+func (r *randomizer) value(old byte, rg fieldRange, discrete bool) byte {
+	step := max(int(rg.Step), 1)
+	n := (int(rg.Max)-int(rg.Min))/step + 1
+
+	if discrete || r.amount >= 1 {
+		if r.rng.Float64() >= r.amount {
+			return old
+		}
+		return rg.Min + byte(r.rng.Intn(n)*step)
+	}
+
+	if r.amount == 0 {
+		return old
+	}
+	span := float64(rg.Max) - float64(rg.Min)
+	v := float64(old) + (r.rng.Float64()*2-1)*r.amount*span
+	k := math.Round((v - float64(rg.Min)) / float64(step))
+	k = math.Max(0, math.Min(k, float64(n-1)))
+	return rg.Min + byte(int(k)*step)
+}
+
+func (r *randomizer) locked(path string) bool {
+	pattern := pathPattern(path)
+	for _, l := range r.locks {
+		l = strings.TrimSpace(l)
+		for _, p := range []string{path, pattern} {
+			if p == l || strings.HasPrefix(p, l+".") || strings.HasPrefix(p, l+"[") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// pathPattern replaces the array indices in a JSON path with "[]", giving
+// the keys used by displayUnits and discreteFields.
+func pathPattern(path string) string {
+	var sb strings.Builder
+	inIndex := false
+	for _, c := range path {
+		switch {
+		case c == '[':
+			inIndex = true
+			sb.WriteString("[]")
+		case c == ']':
+			inIndex = false
+		case !inIndex:
+			sb.WriteRune(c)
+		}
+	}
+	return sb.String()
+}
+
+func randomizePatch(blo *Blofeld, args []string) {
+	fs := flag.NewFlagSet("randomize", flag.ExitOnError)
+	seed := fs.Int64("seed", 0, "seed of the random generator; the same seed, source and options give the same patch (default: a new seed)")
+	amount := fs.Float64("amount", 0.3, "how far to move from the source, 0 to 1")
+	sections := fs.String("sections", "", "comma-separated sections to randomize (default: all): "+strings.Join(randomSectionNames(), ", "))
+	locks := fs.String("locks", "", "comma-separated JSON paths to keep, e.g. filters[0].cutoff,oscillators[].octave")
+	format := fs.String("format", "raw", "format of JSON patch files and of the output: raw or display")
+	load := fs.Int("load", 0, "load the result into the edit buffer of this instrument (1-16) instead of only printing it")
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		log.Fatalf("usage: randomize [-seed N] [-amount 0.3] [-sections filters,effects] [-locks path,...] [-format raw|display] [-load N] <source> (a file, a slot like A001, edit or edit:N)")
+	}
+
+	p, err := loadPatchArg(blo, fs.Arg(0), *format)
+	if err != nil {
+		log.Fatalf("failed to load %s: %v", fs.Arg(0), err)
+	}
+
+	opts := RandomizeOptions{Amount: *amount, Seed: seedFlag(fs, *seed)}
+	if *sections != "" {
+		opts.Sections = strings.Split(*sections, ",")
+	}
+	if *locks != "" {
+		opts.Locks = strings.Split(*locks, ",")
+	}
+	if err := p.Randomize(opts); err != nil {
+		log.Fatalf("failed to randomize: %v", err)
+	}
+	log.Printf("Randomized with seed %d\n", opts.Seed)
+
+	if *load > 0 {
+		if err := p.Validate(); err != nil {
+			log.Fatalf("invalid patch: %v", err)
+		}
+		if err := blo.SendInstrument(*load, p); err != nil {
+			log.Fatalf("failed to send patch: %v", err)
+		}
+	}
+
+	asJson, err := marshalPatch(p, *format)
+	if err != nil {
+		log.Fatalf("failed to marshal patch: %v", err)
+	}
+	fmt.Println(string(asJson))
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type RestoreOptions struct {
+	Delay    time.Duration // pause after each SNDD so the Blofeld can store it
+	Verify   bool          // read every slot back and compare
+	Progress func(done, total int)
+}
+
+// RestoreMismatch reports a slot that was not restored as expected.
+type RestoreMismatch struct {
+	Bank    string
+	Program int
+	Reason  string
+}
+
+// RestoreSounds writes the archived sounds back to their slots, pacing the
+// messages by opts.Delay. Each archived SNDD frame is sent as it is, with
+// only the device ID and location rewritten. Invalid archive entries are
+// skipped and reported. With opts.Verify each slot is read back and compared
+// to what was sent.
+func (b *Blofeld) RestoreSounds(sounds []ArchivedSound, opts RestoreOptions) ([]RestoreMismatch, error) {
+	var mismatches []RestoreMismatch
+	devID := b.DeviceID()
+
+	for i, s := range sounds {
+		if opts.Progress != nil {
+			opts.Progress(i, len(sounds))
+		}
+
+		if s.Error != "" {
+			mismatches = append(mismatches, RestoreMismatch{s.Bank, s.Program, "invalid in archive: " + s.Error})
+			continue
+		}
+
+		sdata, err := checkSNDD(s.SysEx)
+		if err != nil {
+			mismatches = append(mismatches, RestoreMismatch{s.Bank, s.Program, "invalid in archive: " + err.Error()})
+			continue
+		}
+
+		bankByte, err := bankToByte(s.Bank)
+		if err != nil {
+			mismatches = append(mismatches, RestoreMismatch{s.Bank, s.Program, err.Error()})
+			continue
+		}
+		if s.Program < 1 || s.Program > 128 {
+			mismatches = append(mismatches, RestoreMismatch{s.Bank, s.Program, fmt.Sprintf("program must be in range 1–128, got %d", s.Program)})
+			continue
+		}
+
+		frame := append([]byte(nil), s.SysEx...)
+		frame[3], frame[5], frame[6] = devID, bankByte, byte(s.Program-1)
+		if err := b.SendSysEx(frame); err != nil {
+			return mismatches, fmt.Errorf("failed to send %s%03d: %w", s.Bank, s.Program, err)
+		}
+		time.Sleep(opts.Delay)
+
+		if !opts.Verify {
+			continue
+		}
+
+		got, _, err := b.RequestPatchDump(s.Bank, s.Program)
+		if err != nil {
+			mismatches = append(mismatches, RestoreMismatch{s.Bank, s.Program, "read back failed: " + err.Error()})
+			continue
+		}
+		if !bytes.Equal(got.Raw, sdata) {
+			mismatches = append(mismatches, RestoreMismatch{s.Bank, s.Program, "read back differs from sent sound"})
+		}
+	}
+
+	if opts.Progress != nil {
+		opts.Progress(len(sounds), len(sounds))
+	}
+
+	return mismatches, nil
+}
+
+// parseBankRange parses "A" or "A-C" into inclusive bank bytes.
+func parseBankRange(banks string) (byte, byte, error) {
+	from, to, found := strings.Cut(banks, "-")
+	if !found {
+		to = from
+	}
+
+	first, err := bankToByte(strings.TrimSpace(from))
+	if err != nil {
+		return 0, 0, err
+	}
+	last, err := bankToByte(strings.TrimSpace(to))
+	if err != nil {
+		return 0, 0, err
+	}
+	if first > last {
+		return 0, 0, fmt.Errorf("bank range %q is reversed", banks)
+	}
+	return first, last, nil
+}
+
+func restoreSounds(blo *Blofeld, args []string) {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	banks := fs.String("banks", "A-H", "bank range to restore, e.g. A or B-D")
+	delay := fs.Duration("delay", 200*time.Millisecond, "pause between sounds")
+	verify := fs.Bool("verify", false, "read every slot back and compare")
+	start := fs.String("start", "", "for .syx files: first slot to write, e.g. B001 (default: locations stored in the file)")
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		log.Fatalf("usage: restore [-banks A-H] [-delay 200ms] [-verify] [-start B001] <archive.json|file.syx>")
+	}
+
+	first, last, err := parseBankRange(*banks)
+	if err != nil {
+		log.Fatalf("invalid bank range: %v", err)
+	}
+
+	var archive *SoundArchive
+	if strings.EqualFold(filepath.Ext(fs.Arg(0)), ".syx") {
+		archive, err = syxArchive(fs.Arg(0), blo.DeviceID(), *start)
+	} else {
+		archive, err = ReadSoundArchive(fs.Arg(0))
+	}
+	if err != nil {
+		log.Fatalf("failed to read archive: %v", err)
+	}
+
+	var sounds []ArchivedSound
+	for _, s := range archive.Sounds {
+		bank, err := bankToByte(s.Bank)
+		if err != nil {
+			log.Printf("skipping sound %s%03d: %v\n", s.Bank, s.Program, err)
+			continue
+		}
+		if bank >= first && bank <= last {
+			sounds = append(sounds, s)
+		}
+	}
+	if len(sounds) == 0 {
+		log.Fatalf("archive has no sounds in banks %s", *banks)
+	}
+
+	mismatches, err := blo.RestoreSounds(sounds, RestoreOptions{
+		Delay:  *delay,
+		Verify: *verify,
+		Progress: func(done, total int) {
+			if done%64 == 0 || done == total {
+				log.Printf("Restored %d/%d sounds\n", done, total)
+			}
+		},
+	})
+	if err != nil {
+		log.Fatalf("restore failed: %v", err)
+	}
+
+	for _, m := range mismatches {
+		log.Printf("mismatch at %s%03d: %s\n", m.Bank, m.Program, m.Reason)
+	}
+	if len(mismatches) > 0 {
+		log.Fatalf("%d of %d sounds were not restored cleanly", len(mismatches), len(sounds))
+	}
+	log.Printf("Restored %d sounds\n", len(sounds))
+}
+
+// syxArchive loads a .syx file as an archive, optionally moving its sounds to
+// consecutive slots starting at start.
+func syxArchive(path string, devID byte, start string) (*SoundArchive, error) {
+	sounds, err := ReadSyxFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if start != "" {
+		bank, program, err := parseSlot(start)
+		if err != nil {
+			return nil, err
+		}
+		bankByte, _ := bankToByte(bank)
+		if err := RelocateSounds(sounds, bankByte, byte(program-1)); err != nil {
+			return nil, err
+		}
+	}
+
+	archive := &SoundArchive{Created: time.Now(), DeviceID: devID}
+	for i, s := range sounds {
+		if s.Bank == editBufferBank {
+			log.Printf("skipping sound %d of %s: it is an edit buffer dump (7F %02X), use -start to restore it to a slot\n", i+1, path, s.Program)
+			continue
+		}
+		frame, err := s.Patch.ToSNDD(devID, s.Bank, s.Program)
+		if err != nil {
+			return nil, err
+		}
+		archive.Sounds = append(archive.Sounds, newArchivedSound(frame))
+	}
+	return archive, nil
+}
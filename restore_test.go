@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// archivedSound returns an archive entry for slot bank/program whose frame
+// was dumped by device 0x05 from another location.
+func archivedSound(t *testing.T, name string, bank string, program int) ArchivedSound {
+	t.Helper()
+	sdata := initSDATA()
+	copy(sdata[nameIdx:], NormalizeName(name))
+	sdata[200] = 0x2A // reserved byte, must be restored verbatim
+	s := newArchivedSound(snddMessage(0x05, 0x07, 0x7F, sdata))
+	s.Bank, s.Program = bank, program
+	return s
+}
+
+func TestRestoreSounds(t *testing.T) {
+	emu := NewEmulator(0x00)
+	tr := emu.Transport()
+	blo := NewBlofeld(0x00, tr)
+
+	broken := archivedSound(t, "Broken", "C", 3)
+	broken.Error = "checksum mismatch"
+	sounds := []ArchivedSound{archivedSound(t, "First", "A", 1), archivedSound(t, "Second", "B", 2), broken}
+
+	const delay = 10 * time.Millisecond
+	start := time.Now()
+	mismatches, err := blo.RestoreSounds(sounds, RestoreOptions{Delay: delay, Verify: true})
+	if err != nil {
+		t.Fatalf("failed to restore: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 2*delay {
+		t.Errorf("two sounds restored in %v, expected at least %v", elapsed, 2*delay)
+	}
+	if len(mismatches) != 1 || mismatches[0].Bank != "C" || mismatches[0].Program != 3 {
+		t.Errorf("expected C003 to be reported, got %+v", mismatches)
+	}
+
+	// The archived frame is sent as it is, moved to its slot and device ID.
+	want := append([]byte(nil), sounds[1].SysEx...)
+	want[3], want[5], want[6] = 0x00, 0x01, 0x01
+	var sent bool
+	for _, msg := range tr.Sent() {
+		sent = sent || bytes.Equal(msg, want)
+	}
+	if !sent {
+		t.Errorf("B002 was not sent as the archived frame")
+	}
+
+	p, _, err := blo.RequestPatchDump("B", 2)
+	if err != nil || p.Name != NormalizeName("Second") || p.Raw[200] != 0x2A {
+		t.Errorf("B002 not restored: %+v, %v", p, err)
+	}
+}
+
+func TestRestoreSoundsMismatch(t *testing.T) {
+	emu := NewEmulator(0x00)
+	tr := &MemoryTransport{Reply: func(msg []byte) [][]byte {
+		if len(msg) > 6 && msg[4] == 0x10 && msg[5] == 0x01 {
+			return nil // bank B is write protected
+		}
+		return emu.Handle(msg)
+	}}
+	blo := NewBlofeld(0x00, tr)
+
+	sounds := []ArchivedSound{archivedSound(t, "Kept", "A", 1), archivedSound(t, "Lost", "B", 1)}
+	mismatches, err := blo.RestoreSounds(sounds, RestoreOptions{Verify: true})
+	if err != nil {
+		t.Fatalf("failed to restore: %v", err)
+	}
+	if len(mismatches) != 1 || mismatches[0].Bank != "B" || mismatches[0].Reason != "read back differs from sent sound" {
+		t.Errorf("expected B001 to differ, got %+v", mismatches)
+	}
+
+	// Without verification nothing is read back.
+	mismatches, err = blo.RestoreSounds(sounds, RestoreOptions{})
+	if err != nil || len(mismatches) != 0 {
+		t.Errorf("expected no mismatches without verify, got %+v, %v", mismatches, err)
+	}
+}
+
+func TestParseBankRange(t *testing.T) {
+	tests := []struct {
+		in          string
+		first, last byte
+		ok          bool
+	}{
+		{"A", 0, 0, true},
+		{"b-d", 1, 3, true},
+		{"A - H", 0, 7, true},
+		{"D-B", 0, 0, false},
+		{"A-I", 0, 0, false},
+		{"", 0, 0, false},
+	}
+	for _, tt := range tests {
+		first, last, err := parseBankRange(tt.in)
+		if (err == nil) != tt.ok || first != tt.first || last != tt.last {
+			t.Errorf("parseBankRange(%q) = %d, %d, %v", tt.in, first, last, err)
+		}
+	}
+}
+
+func TestSyxArchiveEditBuffer(t *testing.T) {
+	// testdata/init.syx holds an edit buffer dump (location 7F 00).
+	archive, err := syxArchive("testdata/init.syx", 0x00, "")
+	if err != nil {
+		t.Fatalf("failed to read: %v", err)
+	}
+	if len(archive.Sounds) != 0 {
+		t.Errorf("edit buffer dump restored without a slot: %+v", archive.Sounds)
+	}
+
+	archive, err = syxArchive("testdata/init.syx", 0x00, "B001")
+	if err != nil {
+		t.Fatalf("failed to read: %v", err)
+	}
+	if len(archive.Sounds) != 1 || archive.Sounds[0].Bank != "B" || archive.Sounds[0].Program != 1 {
+		t.Errorf("expected the sound in B001, got %+v", archive.Sounds)
+	}
+}
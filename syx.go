@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+// SyxSound is a single sound stored in a .syx file as an SNDD frame.
+type SyxSound struct {
+	Bank    byte // location high byte: 0..7 = A..H, 7F = edit buffer
+	Program byte // location low byte: 0..127
+	Patch   *Patch
+}
+
+// splitSysEx cuts a raw byte stream into F0..F7 frames. Bytes outside of a
+// frame are ignored, as MIDI librarians sometimes pad their files.
+func splitSysEx(data []byte) ([][]byte, error) {
+	var frames [][]byte
+	for {
+		start := bytes.IndexByte(data, 0xF0)
+		if start < 0 {
+			return frames, nil
+		}
+		end := bytes.IndexByte(data[start:], 0xF7)
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated SysEx frame at offset %d", start)
+		}
+		frames = append(frames, data[start:start+end+1])
+		data = data[start+end+1:]
+	}
+}
+
+// ReadSyx reads every Blofeld SNDD frame from r. Each frame is validated
+// with the same checks as a received dump; other SysEx messages, including
+// those of other manufacturers, are skipped.
+func ReadSyx(r io.Reader) ([]SyxSound, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	frames, err := splitSysEx(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var sounds []SyxSound
+	for i, frame := range frames {
+		if len(frame) < 5 || frame[1] != 0x3E || frame[2] != 0x13 || frame[4] != 0x10 {
+			continue
+		}
+
+		sdata, err := checkSNDD(frame)
+		if err != nil {
+			return nil, fmt.Errorf("frame %d: %w", i+1, err)
+		}
+
+		p, err := ParseSDATA(sdata)
+		if err != nil {
+			return nil, fmt.Errorf("frame %d: %w", i+1, err)
+		}
+
+		sounds = append(sounds, SyxSound{Bank: frame[5], Program: frame[6], Patch: p})
+	}
+
+	if len(sounds) == 0 {
+		return nil, fmt.Errorf("no sound dumps found")
+	}
+	return sounds, nil
+}
+
+// WriteSyx writes the sounds as consecutive SNDD frames.
+func WriteSyx(w io.Writer, devID byte, sounds []SyxSound) error {
+	for _, s := range sounds {
+		frame, err := s.Patch.ToSNDD(devID, s.Bank, s.Program)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func ReadSyxFile(path string) ([]SyxSound, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sounds, err := ReadSyx(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return sounds, nil
+}
+
+func WriteSyxFile(path string, devID byte, sounds []SyxSound) error {
+	var buf bytes.Buffer
+	if err := WriteSyx(&buf, devID, sounds); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
+
+// RelocateSounds rewrites the locations of the sounds so they occupy
+// consecutive slots starting at bank/program, continuing into the next bank.
+func RelocateSounds(sounds []SyxSound, bank byte, program byte) error {
+	if bank == editBufferBank {
+		if len(sounds) != 1 {
+			return fmt.Errorf("only one sound fits the edit buffer, got %d", len(sounds))
+		}
+		sounds[0].Bank, sounds[0].Program = bank, program
+		return nil
+	}
+
+	start := int(bank)*128 + int(program)
+	if start+len(sounds) > allSoundsCount {
+		return fmt.Errorf("%d sounds starting at %s%03d run past bank H", len(sounds), byteToBank(bank), program+1)
+	}
+
+	for i := range sounds {
+		slot := start + i
+		sounds[i].Bank = byte(slot / 128)
+		sounds[i].Program = byte(slot % 128)
+	}
+	return nil
+}
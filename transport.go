@@ -0,0 +1,110 @@
+package main
+
+import (
+	"sync"
+
+	"gitlab.com/gomidi/midi/v2"
+	"gitlab.com/gomidi/midi/v2/drivers"
+)
+
+// Transport carries MIDI messages to and from a Blofeld.
+type Transport interface {
+	// Send transmits one complete MIDI message.
+	Send(msg []byte) error
+	// ListenSysEx calls fn with every SysEx message received until stop is
+	// called. fn must not keep msg after it returns.
+	ListenSysEx(fn func(msg []byte)) (stop func(), err error)
+}
+
+// rtmidiTransport is the Transport of a Blofeld connected via MIDI ports.
+type rtmidiTransport struct {
+	in  drivers.In
+	out drivers.Out
+}
+
+func (t *rtmidiTransport) Send(msg []byte) error {
+	if !t.out.IsOpen() {
+		if err := t.out.Open(); err != nil {
+			return err
+		}
+	}
+	return t.out.Send(msg)
+}
+
+func (t *rtmidiTransport) ListenSysEx(fn func(msg []byte)) (func(), error) {
+	return midi.ListenTo(t.in, func(msg midi.Message, _ int32) {
+		if len(msg) > 0 && msg[0] == 0xF0 {
+			fn(msg)
+		}
+	}, midi.UseSysEx(), midi.SysExBufferSize(2048))
+}
+
+// MemoryTransport is an in-memory Transport for tests. It records every
+// sent message and answers with the messages returned by Reply.
+type MemoryTransport struct {
+	// Reply, if set, is called for every sent message; the messages it
+	// returns are delivered to the listeners before Send returns.
+	Reply func(msg []byte) [][]byte
+
+	mu        sync.Mutex
+	sent      [][]byte
+	listeners map[int]func([]byte)
+	nextID    int
+}
+
+func (t *MemoryTransport) Send(msg []byte) error {
+	t.mu.Lock()
+	t.sent = append(t.sent, append([]byte(nil), msg...))
+	reply := t.Reply
+	t.mu.Unlock()
+
+	if reply != nil {
+		t.Deliver(reply(msg)...)
+	}
+	return nil
+}
+
+func (t *MemoryTransport) ListenSysEx(fn func(msg []byte)) (func(), error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.listeners == nil {
+		t.listeners = make(map[int]func([]byte))
+	}
+	id := t.nextID
+	t.nextID++
+	t.listeners[id] = fn
+
+	return func() {
+		t.mu.Lock()
+		delete(t.listeners, id)
+		t.mu.Unlock()
+	}, nil
+}
+
+// Deliver passes msgs to the listeners as if they were received from the
+// Blofeld. Messages other than SysEx are dropped, like by rtmidiTransport.
+func (t *MemoryTransport) Deliver(msgs ...[]byte) {
+	t.mu.Lock()
+	listeners := make([]func([]byte), 0, len(t.listeners))
+	for _, fn := range t.listeners {
+		listeners = append(listeners, fn)
+	}
+	t.mu.Unlock()
+
+	for _, msg := range msgs {
+		if len(msg) == 0 || msg[0] != 0xF0 {
+			continue
+		}
+		for _, fn := range listeners {
+			fn(msg)
+		}
+	}
+}
+
+// Sent returns the messages sent so far.
+func (t *MemoryTransport) Sent() [][]byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([][]byte(nil), t.sent...)
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testSound returns the init sound renamed to name.
+func testSound(t *testing.T, name string) *Patch {
+	t.Helper()
+	data := initSDATA()
+	copy(data[nameIdx:], NormalizeName(name))
+	p, err := ParseSDATA(data)
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	return p
+}
+
+// soundReplies answers every SNDR with the SNDD of sound.
+func soundReplies(t *testing.T, sound *Patch) func(msg []byte) [][]byte {
+	return func(msg []byte) [][]byte {
+		if len(msg) != 8 || msg[4] != 0x00 {
+			return nil
+		}
+		sndd, err := sound.ToSNDD(msg[3], msg[5], msg[6])
+		if err != nil {
+			t.Errorf("failed to build SNDD: %v", err)
+			return nil
+		}
+		return [][]byte{sndd}
+	}
+}
+
+func TestRequestPatchDump(t *testing.T) {
+	sound := testSound(t, "Memory Pad")
+	tr := &MemoryTransport{Reply: soundReplies(t, sound)}
+	blo := NewBlofeld(0x12, tr)
+
+	p, devID, err := blo.RequestPatchDump("C", 5)
+	if err != nil {
+		t.Fatalf("failed to request patch: %v", err)
+	}
+	if p.Name != sound.Name || devID != 0x12 {
+		t.Errorf("got %q from device 0x%02X", p.Name, devID)
+	}
+
+	want := []byte{0xF0, 0x3E, 0x13, 0x12, 0x00, 0x02, 0x04, 0xF7}
+	if sent := tr.Sent(); len(sent) != 1 || !bytes.Equal(sent[0], want) {
+		t.Errorf("sent % X, want % X", sent, want)
+	}
+
+	if _, _, err := blo.RequestPatchDump("I", 1); err == nil {
+		t.Errorf("expected an error for bank I")
+	}
+}
+
+func TestSendAndSaveSound(t *testing.T) {
+	sound := testSound(t, "Stored")
+	tr := &MemoryTransport{Reply: soundReplies(t, sound)}
+	blo := NewBlofeld(0x00, tr)
+
+	if err := blo.SaveInstrument(3, "B", 10); err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+	if err := blo.SetInstrumentParameter(3, 363, 'X'); err != nil {
+		t.Fatalf("failed to set parameter: %v", err)
+	}
+
+	sent := tr.Sent()
+	if len(sent) != 3 {
+		t.Fatalf("expected SNDR, SNDD and SNDP, got %d messages", len(sent))
+	}
+	if want := []byte{0xF0, 0x3E, 0x13, 0x00, 0x00, 0x7F, 0x02, 0xF7}; !bytes.Equal(sent[0], want) {
+		t.Errorf("request % X, want % X", sent[0], want)
+	}
+	sndd, _ := sound.ToSNDD(0x00, 0x01, 0x09)
+	if !bytes.Equal(sent[1], sndd) {
+		t.Errorf("stored sound differs from the edit buffer")
+	}
+	if want := []byte{0xF0, 0x3E, 0x13, 0x00, 0x20, 0x02, 0x02, 0x6B, 'X', 0xF7}; !bytes.Equal(sent[2], want) {
+		t.Errorf("SNDP % X, want % X", sent[2], want)
+	}
+}
+
+func TestRequestGlobalDump(t *testing.T) {
+	g := &GlobalSettings{MIDIChannel: 3, DeviceID: 0x05, Volume: 90}
+	tr := &MemoryTransport{Reply: func(msg []byte) [][]byte {
+		if msg[4] == 0x04 {
+			return [][]byte{{0xF0, 0x3E, 0x13, 0x05, 0x10, 0xF7}, g.ToGLBD(0x05)} // an unrelated reply first
+		}
+		return nil
+	}}
+	blo := NewBlofeld(0x00, tr)
+
+	got, err := blo.DiscoverGlobals()
+	if err != nil {
+		t.Fatalf("failed to discover: %v", err)
+	}
+	got.Raw = nil
+	if !reflect.DeepEqual(got, g) {
+		t.Errorf("expected %+v, got %+v", g, got)
+	}
+	if sent := tr.Sent(); sent[0][3] != broadcastDeviceID {
+		t.Errorf("discovery sent to device 0x%02X", sent[0][3])
+	}
+}
+
+func TestRequestAllSounds(t *testing.T) {
+	sound := testSound(t, "Bank Sound")
+	tr := &MemoryTransport{Reply: func(msg []byte) [][]byte {
+		if msg[4] != 0x00 || msg[5] != allSoundsBank {
+			return nil
+		}
+		var frames [][]byte
+		for i := 0; i < allSoundsCount; i++ {
+			sndd, _ := sound.ToSNDD(msg[3], byte(i/128), byte(i%128))
+			if i == 200 {
+				sndd[len(sndd)-2] ^= 0x01 // bad checksum
+			}
+			frames = append(frames, sndd)
+		}
+		return frames
+	}}
+	blo := NewBlofeld(0x00, tr)
+
+	var progress int
+	archive, err := blo.RequestAllSounds(func(received, total int) { progress = received })
+	if err != nil {
+		t.Fatalf("failed to back up: %v", err)
+	}
+	if len(archive.Sounds) != allSoundsCount || progress != allSoundsCount {
+		t.Fatalf("got %d sounds, progress %d", len(archive.Sounds), progress)
+	}
+	if s := archive.Sounds[201]; s.Bank != "B" || s.Program != 74 || s.Name != NormalizeName("Bank Sound") {
+		t.Errorf("unexpected sound %+v", s)
+	}
+	if invalid := archive.Invalid(); len(invalid) != 1 || invalid[0].Program != 73 {
+		t.Errorf("expected B073 to be invalid, got %+v", invalid)
+	}
+}
+
+func TestConcurrentRequests(t *testing.T) {
+	tr := &MemoryTransport{}
+	blo := NewBlofeld(0x00, tr)
+
+	var unsolicited [][]byte
+	unsubscribe, err := blo.Subscribe(func(msg []byte) {
+		unsolicited = append(unsolicited, append([]byte(nil), msg...))
+	})
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer unsubscribe()
+
+	names := map[string]string{"A": "First", "B": "Second"}
+	results := make(map[string]chan string)
+	for bank := range names {
+		results[bank] = make(chan string, 1)
+		go func() {
+			p, _, err := blo.RequestPatchDump(bank, 2)
+			if err != nil {
+				t.Errorf("failed to request %s002: %v", bank, err)
+				results[bank] <- ""
+				return
+			}
+			results[bank] <- p.Name
+		}()
+	}
+	for len(tr.Sent()) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Another manufacturer's SysEx and a second Blofeld's dump of the same
+	// slot arrive first, followed by a knob change.
+	foreign := []byte{0xF0, 0x43, 0x10, 0x00, 0x10, 0x00, 0x01, 0xF7}
+	otherDevice, _ := testSound(t, "Other Blofeld").ToSNDD(0x05, 0x00, 0x01)
+	knob := []byte{0xF0, 0x3E, 0x13, 0x00, 0x20, 0x00, 0x00, 0x2D, 0x40, 0xF7}
+	second, _ := testSound(t, names["B"]).ToSNDD(0x00, 0x01, 0x01)
+	first, _ := testSound(t, names["A"]).ToSNDD(0x00, 0x00, 0x01)
+	tr.Deliver(foreign, otherDevice, knob, second, first)
+
+	for bank, name := range names {
+		if got := <-results[bank]; got != NormalizeName(name) {
+			t.Errorf("%s002: expected %q, got %q", bank, name, got)
+		}
+	}
+	if len(unsolicited) != 3 || !bytes.Equal(unsolicited[0], foreign) || !bytes.Equal(unsolicited[1], otherDevice) || !bytes.Equal(unsolicited[2], knob) {
+		t.Errorf("expected the stray messages to reach the subscriber, got % X", unsolicited)
+	}
+}
+
+func TestRenameInstrument(t *testing.T) {
+	emu := NewEmulator(0x00)
+	tr := emu.Transport()
+	blo := NewBlofeld(0x00, tr)
+
+	pad := Category(4)
+	if err := blo.RenameInstrument(2, "Café Pad  ", &pad); err != nil {
+		t.Fatalf("failed to rename: %v", err)
+	}
+
+	sent := tr.Sent()
+	if len(sent) != nameLength+1 {
+		t.Fatalf("expected %d SNDP messages, got %d", nameLength+1, len(sent))
+	}
+	if want := []byte{0xF0, 0x3E, 0x13, 0x00, 0x20, 0x01, 0x02, 0x6B, 'C', 0xF7}; !bytes.Equal(sent[0], want) {
+		t.Errorf("first SNDP % X, want % X", sent[0], want)
+	}
+
+	p, _, err := blo.RequestInstrument(2)
+	if err != nil {
+		t.Fatalf("failed to read back: %v", err)
+	}
+	if p.Name != "Cafe Pad        " || p.Category != pad {
+		t.Errorf("got name %q, category %v", p.Name, p.Category)
+	}
+
+	for _, instrument := range []int{0, 17} {
+		if err := blo.RenameInstrument(instrument, "Nope", nil); err == nil {
+			t.Errorf("expected an error for instrument %d", instrument)
+		}
+	}
+	bad := Category(200)
+	if err := blo.RenameInstrument(2, "Nope", &bad); err == nil {
+		t.Errorf("expected an error for category 200")
+	}
+	if n := len(tr.Sent()); n != nameLength+2 { // the read back request
+		t.Errorf("invalid renames sent %d messages", n-nameLength-2)
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ApplyPatchUpdate returns a copy of p with changes applied. changes is
+// either a partial patch object, merged field by field (arrays element by
+// element, null elements are skipped), or an RFC 6902 array of "replace",
+// "add" and "test" operations. Paths and values use the given format, "raw"
+// or "display". Unknown fields are rejected, so a typo cannot go unnoticed.
+func ApplyPatchUpdate(p *Patch, changes []byte, format string) (*Patch, error) {
+	data, err := marshalPatch(p, format)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := decodeTree(data)
+	if err != nil {
+		return nil, err
+	}
+	base := tree.(map[string]any)
+
+	// Named effect parameters depend on the effect type. When an update
+	// changes the type, the old parameters it left untouched and the new
+	// type does not have are dropped.
+	origParams := make(map[int]map[string]any)
+	effects, _ := base["effects"].([]any)
+	for i, e := range effects {
+		if params, ok := e.(map[string]any)["parameters"].(map[string]any); ok {
+			origParams[i] = make(map[string]any)
+			for k, v := range params {
+				origParams[i][k] = v
+			}
+		}
+	}
+
+	update, err := decodeTree(changes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse changes: %w", err)
+	}
+
+	switch u := update.(type) {
+	case map[string]any:
+		if err := mergeTree(base, u, ""); err != nil {
+			return nil, err
+		}
+	case []any:
+		for i, op := range u {
+			if err := applyOperation(base, op); err != nil {
+				return nil, fmt.Errorf("operation %d: %w", i, err)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("changes must be a partial patch object or an array of JSON Patch operations")
+	}
+
+	effects, _ = base["effects"].([]any)
+	for i, e := range effects {
+		effect, ok := e.(map[string]any)
+		if !ok {
+			continue
+		}
+		params, ok := effect["parameters"].(map[string]any)
+		if !ok {
+			continue
+		}
+		var typ EffectType
+		asJson, err := json.Marshal(effect["type"])
+		if err != nil || typ.UnmarshalJSON(asJson) != nil {
+			continue // reported when the patch is unmarshalled
+		}
+		for k, v := range params {
+			orig, ok := origParams[i][k]
+			if _, err := lookupEffectParam(typ, k); err != nil && ok && fmt.Sprint(orig) == fmt.Sprint(v) {
+				delete(params, k)
+			}
+		}
+	}
+
+	merged, err := json.Marshal(base)
+	if err != nil {
+		return nil, err
+	}
+	updated := &Patch{}
+	if err := unmarshalPatch(merged, format, updated); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+func mergeTree(base map[string]any, changes map[string]any, path string) error {
+	for k, cv := range changes {
+		p := k
+		if path != "" {
+			p = path + "." + k
+		}
+
+		bv, ok := base[k]
+		if !ok {
+			// Parameters of another effect type are checked once the
+			// effect is decoded.
+			effect, inParams := strings.CutSuffix(path, ".parameters")
+			if (k == "parameters" && isEffectPath(path)) || (inParams && isEffectPath(effect)) {
+				base[k] = cv
+				continue
+			}
+			return fmt.Errorf("unknown field %s", p)
+		}
+
+		switch b := bv.(type) {
+		case map[string]any:
+			c, ok := cv.(map[string]any)
+			if !ok {
+				return fmt.Errorf("%s must be an object", p)
+			}
+			if err := mergeTree(b, c, p); err != nil {
+				return err
+			}
+		case []any:
+			c, ok := cv.([]any)
+			if !ok {
+				return fmt.Errorf("%s must be an array", p)
+			}
+			if len(c) > len(b) {
+				return fmt.Errorf("%s has only %d elements, got %d", p, len(b), len(c))
+			}
+			for i, elem := range c {
+				if elem == nil {
+					continue
+				}
+				ep := fmt.Sprintf("%s[%d]", p, i)
+				if bm, ok := b[i].(map[string]any); ok {
+					cm, ok := elem.(map[string]any)
+					if !ok {
+						return fmt.Errorf("%s must be an object", ep)
+					}
+					if err := mergeTree(bm, cm, ep); err != nil {
+						return err
+					}
+					continue
+				}
+				b[i] = elem
+			}
+		default:
+			switch cv.(type) {
+			case map[string]any, []any:
+				return fmt.Errorf("%s must be a single value", p)
+			}
+			base[k] = cv
+		}
+	}
+	return nil
+}
+
+// applyOperation applies one RFC 6902 operation. The patch structure is
+// fixed, so only values can be replaced; "add" is accepted as a replace.
+// Named effect parameters may be set even if the current type lacks them.
+func applyOperation(base map[string]any, v any) error {
+	op, ok := v.(map[string]any)
+	if !ok {
+		return fmt.Errorf("must be an object")
+	}
+	name, _ := op["op"].(string)
+	ptr, _ := op["path"].(string)
+	value, hasValue := op["value"]
+
+	switch name {
+	case "replace", "add", "test":
+	case "remove", "move", "copy":
+		return fmt.Errorf("%q is not supported, patch fields cannot be removed or moved", name)
+	default:
+		return fmt.Errorf("unknown op %q", name)
+	}
+	if !hasValue {
+		return fmt.Errorf("%s %s: missing value", name, ptr)
+	}
+
+	if !strings.HasPrefix(ptr, "/") {
+		return fmt.Errorf("path %q must start with /", ptr)
+	}
+	tokens := strings.Split(ptr[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(t)
+	}
+
+	// Walk to the parent of the target.
+	var parent any = base
+	for i, t := range tokens[:len(tokens)-1] {
+		child, err := jsonPointerChild(parent, t, ptr)
+		if err != nil && t == "parameters" && isEffectPointer(tokens[:i]) {
+			// A bypassed effect has no parameters yet.
+			child, err = make(map[string]any), nil
+			parent.(map[string]any)[t] = child
+		}
+		if err != nil {
+			return err
+		}
+		parent = child
+	}
+
+	last := tokens[len(tokens)-1]
+	current, err := jsonPointerChild(parent, last, ptr)
+	namedParam := len(tokens) == 4 && isEffectPointer(tokens[:2]) && tokens[2] == "parameters"
+	if err != nil && !(namedParam && name != "test") {
+		return err
+	}
+
+	if name == "test" {
+		if fmt.Sprint(current) != fmt.Sprint(value) {
+			return fmt.Errorf("test failed: %s is %v, not %v", ptr, current, value)
+		}
+		return nil
+	}
+
+	switch current.(type) {
+	case map[string]any, []any:
+		return fmt.Errorf("%s is not a single value", ptr)
+	}
+
+	switch t := parent.(type) {
+	case map[string]any:
+		t[last] = value
+	case []any:
+		i, _ := strconv.Atoi(last)
+		t[i] = value
+	}
+	return nil
+}
+
+func jsonPointerChild(parent any, token string, ptr string) (any, error) {
+	switch t := parent.(type) {
+	case map[string]any:
+		child, ok := t[token]
+		if !ok {
+			return nil, fmt.Errorf("unknown field in %s", ptr)
+		}
+		return child, nil
+	case []any:
+		i, err := strconv.Atoi(token)
+		if err != nil || i < 0 || i >= len(t) {
+			return nil, fmt.Errorf("index %q out of range in %s", token, ptr)
+		}
+		return t[i], nil
+	case nil:
+		return nil, fmt.Errorf("unknown field in %s", ptr)
+	}
+	return nil, fmt.Errorf("%s descends into a single value", ptr)
+}
+
+// isEffectPath reports whether a merge path such as "effects[1]" names an
+// effect unit, the only place named parameters may appear.
+func isEffectPath(path string) bool {
+	idx, ok := strings.CutPrefix(path, "effects[")
+	if !ok {
+		return false
+	}
+	idx, ok = strings.CutSuffix(idx, "]")
+	return ok && isEffectPointer([]string{"effects", idx})
+}
+
+// isEffectPointer is isEffectPath for JSON Pointer tokens such as
+// ["effects", "1"].
+func isEffectPointer(tokens []string) bool {
+	if len(tokens) != 2 || tokens[0] != "effects" {
+		return false
+	}
+	i, err := strconv.Atoi(tokens[1])
+	return err == nil && i >= 0 && i < len(effectFieldMapping)
+}
+
+// changedParameters lists the SDATA indices that differ between two
+// patches, for sending them one by one with SNDP.
+func changedParameters(old, updated *Patch) ([]paramUpdate, error) {
+	before, err := old.ToSDATA()
+	if err != nil {
+		return nil, err
+	}
+	after, err := updated.ToSDATA()
+	if err != nil {
+		return nil, err
+	}
+
+	var updates []paramUpdate
+	for i := range after {
+		if after[i] != before[i] {
+			updates = append(updates, paramUpdate{i, after[i]})
+		}
+	}
+	return updates, nil
+}
+
+// WriteUpdate stores updated at l. Edit buffers get SNDP messages for the
+// changed indices only; stored slots can only be written as a whole (SNDD).
+// It returns the number of messages sent.
+func (b *Blofeld) WriteUpdate(l patchLocation, old, updated *Patch) (int, error) {
+	if l.Bank != "" {
+		if err := b.SendPatch(l.Bank, l.Program, updated, b.DeviceID()); err != nil {
+			return 0, err
+		}
+		return 1, nil
+	}
+
+	updates, err := changedParameters(old, updated)
+	if err != nil {
+		return 0, err
+	}
+	for i, u := range updates {
+		if err := b.SetInstrumentParameter(l.Instrument, u.Index, u.Value); err != nil {
+			return i, err
+		}
+	}
+	return len(updates), nil
+}
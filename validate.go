@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// FieldError is a patch field outside the range documented in spec 3.1.
+type FieldError struct {
+	Path  string `json:"path"` // JSON path, e.g. "oscillators[2].shape"
+	Value int    `json:"value"`
+	Min   int    `json:"min"`
+	Max   int    `json:"max"`
+	Step  int    `json:"step,omitempty"` // set when only every Step-th value from Min is valid
+}
+
+func (e FieldError) Error() string {
+	if e.Step > 0 {
+		return fmt.Sprintf("%s: %d is not in range %d–%d in steps of %d", e.Path, e.Value, e.Min, e.Max, e.Step)
+	}
+	return fmt.Sprintf("%s: %d is out of range %d–%d", e.Path, e.Value, e.Min, e.Max)
+}
+
+// ValidationErrors lists every invalid field of a patch.
+type ValidationErrors []FieldError
+
+func (v ValidationErrors) Error() string {
+	msgs := make([]string, len(v))
+	for i, e := range v {
+		msgs[i] = e.Error()
+	}
+	return fmt.Sprintf("%d invalid fields: %s", len(v), strings.Join(msgs, "; "))
+}
+
+// fieldRange is the valid range of a field: every step-th value from Min
+// to Max.
+type fieldRange struct {
+	Min, Max, Step byte
+}
+
+type validator struct {
+	errs ValidationErrors
+	seen map[string]bool
+
+	// ranges, if not nil, collects the range of every checked field. The
+	// first check of a path wins, like for errors.
+	ranges map[string]fieldRange
+}
+
+func (v *validator) check(path string, value byte, min, max byte) {
+	v.checkStep(path, value, min, max, 1)
+}
+
+func (v *validator) checkStep(path string, value byte, min, max, step byte) {
+	if v.ranges != nil {
+		if _, ok := v.ranges[path]; !ok {
+			v.ranges[path] = fieldRange{min, max, step}
+		}
+	}
+	if v.seen[path] || (value >= min && value <= max && (value-min)%step == 0) {
+		return
+	}
+	if v.seen == nil {
+		v.seen = make(map[string]bool)
+	}
+	v.seen[path] = true
+
+	e := FieldError{Path: path, Value: int(value), Min: int(min), Max: int(max)}
+	if step > 1 {
+		e.Step = int(step)
+	}
+	v.errs = append(v.errs, e)
+}
+
+// checkBytes walks the struct by its JSON names and checks that every byte
+// field fits into 7 bits. The narrower ranges are checked explicitly.
+func (v *validator) checkBytes(path string, val reflect.Value) {
+	switch val.Kind() {
+	case reflect.Uint8:
+		v.check(path, byte(val.Uint()), 0, 127)
+	case reflect.Array:
+		for i := 0; i < val.Len(); i++ {
+			v.checkBytes(fmt.Sprintf("%s[%d]", path, i), val.Index(i))
+		}
+	case reflect.Struct:
+		typ := val.Type()
+		for i := 0; i < typ.NumField(); i++ {
+			name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+			if name == "" || name == "-" {
+				continue
+			}
+			if path != "" {
+				name = path + "." + name
+			}
+			v.checkBytes(name, val.Field(i))
+		}
+	}
+}
+
+// Validate checks every field against the ranges in spec 3.1 and the effect
+// parameter ranges of spec 5. It returns ValidationErrors listing all invalid
+// fields, or nil.
+func (p *Patch) Validate() error {
+	var v validator
+	p.checkFields(&v)
+
+	if len(v.errs) > 0 {
+		return v.errs
+	}
+	return nil
+}
+
+// fieldRanges returns the valid range of every byte field by its JSON path,
+// e.g. "oscillators[2].shape". Effect parameters depend on the current
+// effect types and are listed as "effects[1].parameters.decay".
+func (p *Patch) fieldRanges() map[string]fieldRange {
+	v := validator{ranges: make(map[string]fieldRange)}
+	p.checkFields(&v)
+	return v.ranges
+}
+
+func (p *Patch) checkFields(v *validator) {
+	for i, osc := range p.Oscillators {
+		path := fmt.Sprintf("oscillators[%d]", i)
+		v.checkStep(path+".octave", osc.Octave, 16, 112, 12)
+		v.check(path+".pitch", osc.Pitch, 52, 76)
+		v.check(path+".bend_range", osc.BendRange, 40, 88)
+		v.check(path+".fm_source", byte(osc.FMSource), 0, 11)
+		maxShape := byte(72)
+		if i == 2 {
+			maxShape = 4 // Osc 3 has no wavetables
+		}
+		v.check(path+".shape", byte(osc.Shape), 0, maxShape)
+		v.check(path+".pwm_source", byte(osc.PWMSource), 0, 30)
+		v.check(path+".limit_wt", osc.LimitWT, 0, 1)
+	}
+	v.check("osc2_sync", p.Osc2Sync, 0, 1)
+	v.check("osc_pitch_source", byte(p.OscPitchSource), 0, 30)
+	v.check("glide", p.Glide, 0, 1)
+	v.check("glide_mode", p.GlideMode, 0, 3)
+	v.check("unison.voices", p.Unison.Voices, 0, 5)
+
+	for i, f := range p.Filters {
+		path := fmt.Sprintf("filters[%d]", i)
+		v.check(path+".type", byte(f.Type), 0, 11)
+		v.check(path+".drive_curve", byte(f.DriveCurve), 0, 12)
+		v.check(path+".mod_source", byte(f.ModSource), 0, 30)
+		v.check(path+".fm_source", byte(f.FMSource), 0, 11)
+		v.check(path+".pan_source", byte(f.PanSource), 0, 30)
+	}
+	v.check("filter_routing", p.FilterRouting, 0, 1)
+	v.check("amp_mod_source", byte(p.AmpModSource), 0, 30)
+
+	for i, e := range p.Effects {
+		path := fmt.Sprintf("effects[%d]", i)
+		maxType := byte(EffectTripleFX)
+		if i == 1 {
+			maxType = byte(EffectReverb)
+		}
+		v.check(path+".type", byte(e.Type), 0, maxType)
+		for _, prm := range effectParams[e.Type] {
+			v.check(fmt.Sprintf("%s.parameters.%s", path, prm.Name), e.Params[prm.Slot], 0, prm.Max)
+		}
+	}
+
+	for i, lfo := range p.LFOs {
+		path := fmt.Sprintf("lfos[%d]", i)
+		v.check(path+".shape", byte(lfo.Shape), 0, 5)
+		v.check(path+".sync", lfo.Sync, 0, 1)
+		v.check(path+".clocked", lfo.Clocked, 0, 1)
+	}
+
+	for i, env := range p.Envelopes {
+		path := fmt.Sprintf("envelopes[%d]", i)
+		v.check(path+".mode", byte(env.Mode), 0, 4)
+		v.check(path+".trigger", byte(env.Trigger), 0, 1)
+	}
+
+	for i, mod := range p.Modifiers {
+		path := fmt.Sprintf("modifiers[%d]", i)
+		v.check(path+".source_a", byte(mod.SourceA), 0, 30)
+		v.check(path+".source_b", byte(mod.SourceB), 0, 30)
+		v.check(path+".operator", byte(mod.Operator), 0, 7)
+	}
+	for i, mod := range p.ModMatrix {
+		path := fmt.Sprintf("mod_matrix[%d]", i)
+		v.check(path+".source", byte(mod.Source), 0, 30)
+		v.check(path+".dest", byte(mod.Dest), 0, 53)
+	}
+
+	v.check("arp_mode", p.ArpMode, 0, 3)
+	v.check("arp_pattern", p.ArpPattern, 0, 16)
+	v.check("arp_clock", p.ArpClock, 0, 42)
+	v.check("arp_length", p.ArpLength, 0, 43)
+	v.check("arp_range", p.ArpRange, 0, 9)
+	v.check("arp_direction", p.ArpDirection, 0, 3)
+	v.check("arp_sort", p.ArpSort, 0, 5)
+	v.check("arp_velocity_mode", p.ArpVelocityMode, 0, 6)
+	v.check("arp_pattern_reset", p.ArpPatternReset, 0, 1)
+	v.check("arp_pattern_length", p.ArpPatternLength, 0, 15)
+	for i, step := range p.ArpPatternSteps {
+		path := fmt.Sprintf("arp_pattern_steps[%d]", i)
+		v.check(path+".kind", byte(step.Kind), 0, 7)
+		v.check(path+".accent", step.Accent, 0, 7)
+	}
+	for i, timing := range p.ArpPatternTiming {
+		path := fmt.Sprintf("arp_pattern_timing[%d]", i)
+		v.check(path+".length", timing.Length, 0, 7)
+		v.check(path+".timing", timing.Timing, 0, 7)
+	}
+
+	// The name needs no check, ToSDATA normalizes it.
+	v.check("category", byte(p.Category), 0, 12)
+
+	v.checkBytes("", reflect.ValueOf(*p))
+}